@@ -1,5 +1,10 @@
 package goentdb
 
+import (
+	"fmt"
+	"strings"
+)
+
 type Origin uint
 
 const (
@@ -21,9 +26,33 @@ var OriginNames = []string{
 	"Pornone",
 	"Cumlouder",
 	"Superporn",
+	"", // 7 is not assigned
 	"Alphaporno",
 }
 
+func (o Origin) String() string {
+	if int(o) < len(OriginNames) && OriginNames[o] != "" {
+		return OriginNames[o]
+	}
+	return fmt.Sprintf("Origin(%d)", uint(o))
+}
+
+/*
+Case-insensitive lookup of Origin by its name in OriginNames
+*/
+func OriginByName(name string) (Origin, bool) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return OriginUnkown, false
+	}
+	for pos, originName := range OriginNames {
+		if strings.EqualFold(originName, name) {
+			return Origin(pos), true
+		}
+	}
+	return OriginUnkown, false
+}
+
 type EntKeywordType uint
 
 const (
@@ -54,192 +83,47 @@ var StopWords = []string{
 	"hasn", "hasn't", "haven", "haven't", "isn", "isn't", "ma", "mightn", "mightn't",
 	"mustn", "mustn't", "needn", "needn't", "shan", "shan't", "shouldn", "shouldn't",
 	"wasn", "wasn't", "weren", "weren't", "won", "won't", "wouldn", "wouldn't",
+	"get", "gg", "go", "hi",
 }
 
 const TrimSymbols = ".!,:-)(/@$%^&?*]["
 
-var StopWordsMap = map[string]bool{
-	"and":        true,
-	"to":         true,
-	"in":         true,
-	"a":          true,
-	"with":       true,
-	"get":        true,
-	"s":          true,
-	"be":         true,
-	"it":         true,
-	"on":         true,
-	"they":       true,
-	"but":        true,
-	"by":         true,
-	"gg":         true,
-	"go":         true,
-	"he":         true,
-	"hi":         true,
-	"not":        true,
-	"of":         true,
-	"than":       true,
-	"the":        true,
-	"their":      true,
-	"what":       true,
-	"i":          true,
-	"me":         true,
-	"my":         true,
-	"myself":     true,
-	"we":         true,
-	"our":        true,
-	"ours":       true,
-	"ourselves":  true,
-	"you":        true,
-	"you're":     true,
-	"you've":     true,
-	"you'll":     true,
-	"you'd":      true,
-	"your":       true,
-	"yours":      true,
-	"yourself":   true,
-	"yourselves": true,
-	"him":        true,
-	"his":        true,
-	"himself":    true,
-	"she":        true,
-	"she's":      true,
-	"her":        true,
-	"hers":       true,
-	"herself":    true,
-	"it's":       true,
-	"its":        true,
-	"itself":     true,
-	"them":       true,
-	"theirs":     true,
-	"themselves": true,
-	"which":      true,
-	"who":        true,
-	"whom":       true,
-	"this":       true,
-	"that":       true,
-	"that'll":    true,
-	"these":      true,
-	"those":      true,
-	"am":         true,
-	"is":         true,
-	"are":        true,
-	"was":        true,
-	"were":       true,
-	"been":       true,
-	"being":      true,
-	"have":       true,
-	"has":        true,
-	"had":        true,
-	"having":     true,
-	"do":         true,
-	"does":       true,
-	"did":        true,
-	"doing":      true,
-	"an":         true,
-	"if":         true,
-	"or":         true,
-	"because":    true,
-	"as":         true,
-	"until":      true,
-	"while":      true,
-	"at":         true,
-	"for":        true,
-	"about":      true,
-	"against":    true,
-	"between":    true,
-	"into":       true,
-	"through":    true,
-	"during":     true,
-	"before":     true,
-	"after":      true,
-	"above":      true,
-	"below":      true,
-	"from":       true,
-	"up":         true,
-	"down":       true,
-	"out":        true,
-	"off":        true,
-	"over":       true,
-	"under":      true,
-	"again":      true,
-	"further":    true,
-	"then":       true,
-	"once":       true,
-	"here":       true,
-	"there":      true,
-	"when":       true,
-	"where":      true,
-	"why":        true,
-	"how":        true,
-	"all":        true,
-	"any":        true,
-	"both":       true,
-	"each":       true,
-	"few":        true,
-	"more":       true,
-	"most":       true,
-	"other":      true,
-	"some":       true,
-	"such":       true,
-	"no":         true,
-	"nor":        true,
-	"only":       true,
-	"own":        true,
-	"same":       true,
-	"so":         true,
-	"too":        true,
-	"very":       true,
-	"t":          true,
-	"can":        true,
-	"will":       true,
-	"just":       true,
-	"don":        true,
-	"don't":      true,
-	"should":     true,
-	"should've":  true,
-	"now":        true,
-	"d":          true,
-	"ll":         true,
-	"m":          true,
-	"o":          true,
-	"re":         true,
-	"ve":         true,
-	"y":          true,
-	"ain":        true,
-	"aren":       true,
-	"aren't":     true,
-	"couldn":     true,
-	"couldn't":   true,
-	"didn":       true,
-	"didn't":     true,
-	"doesn":      true,
-	"doesn't":    true,
-	"hadn":       true,
-	"hadn't":     true,
-	"hasn":       true,
-	"hasn't":     true,
-	"haven":      true,
-	"haven't":    true,
-	"isn":        true,
-	"isn't":      true,
-	"ma":         true,
-	"mightn":     true,
-	"mightn't":   true,
-	"mustn":      true,
-	"mustn't":    true,
-	"needn":      true,
-	"needn't":    true,
-	"shan":       true,
-	"shan't":     true,
-	"shouldn":    true,
-	"shouldn't":  true,
-	"wasn":       true,
-	"wasn't":     true,
-	"weren":      true,
-	"weren't":    true,
-	"won":        true,
-	"won't":      true,
-	"wouldn":     true,
-	"wouldn't":   true,
+/*
+English stop words as the tokenizer folds them, see EntAnalyzer
+*/
+var StopWordsMap = stopWordSet(StopWords)
+
+var StopWordsGerman = []string{
+	"aber", "alle", "als", "also", "am", "an", "auch", "auf", "aus", "bei", "bin", "bis", "bist",
+	"da", "damit", "dann", "das", "dass", "dem", "den", "der", "des", "die", "dies", "diese",
+	"dir", "du", "durch", "ein", "eine", "einem", "einen", "einer", "eines", "er", "es", "für",
+	"hat", "hatte", "ich", "ihr", "im", "in", "ist", "ja", "kein", "mich", "mir", "mit", "nach",
+	"nicht", "noch", "nur", "ob", "oder", "sein", "sich", "sie", "sind", "so", "über", "um",
+	"und", "uns", "unter", "vom", "von", "vor", "war", "was", "weil", "wenn", "wie", "wir",
+	"wird", "zu", "zum", "zur",
+}
+
+var StopWordsFrench = []string{
+	"au", "aux", "avec", "ce", "ces", "cette", "dans", "de", "des", "du", "elle", "en", "et",
+	"est", "il", "ils", "je", "la", "le", "les", "leur", "lui", "ma", "mais", "me", "mes",
+	"moi", "mon", "ne", "nous", "on", "ou", "par", "pas", "pour", "qu", "que", "qui", "sa",
+	"se", "ses", "son", "sont", "sur", "ta", "te", "tes", "toi", "ton", "tu", "un", "une",
+	"vos", "votre", "vous", "été", "être", "très",
+}
+
+var StopWordsSpanish = []string{
+	"a", "al", "algo", "como", "con", "de", "del", "el", "ella", "ellas", "ellos", "en", "entre",
+	"era", "es", "esa", "ese", "eso", "esta", "este", "esto", "fue", "ha", "hay", "la", "las",
+	"le", "les", "lo", "los", "me", "mi", "mis", "muy", "más", "ni", "no", "nos", "o", "para",
+	"pero", "por", "que", "se", "sin", "sobre", "su", "sus", "también", "te", "tu", "un", "una",
+	"uno", "unos", "y", "ya", "yo", "él",
+}
+
+var StopWordsRussian = []string{
+	"а", "без", "бы", "был", "была", "были", "было", "быть", "в", "вам", "вас", "вот", "все",
+	"всё", "вы", "где", "да", "для", "до", "его", "ее", "её", "если", "есть", "еще", "ещё", "же",
+	"за", "и", "из", "или", "им", "их", "к", "как", "когда", "кто", "ли", "мне", "мы", "на",
+	"над", "не", "нет", "ни", "но", "о", "об", "он", "она", "они", "оно", "от", "по", "под",
+	"при", "про", "с", "со", "так", "также", "там", "то", "тоже", "только", "ты", "у", "уже",
+	"что", "чтобы", "это", "этот", "я",
 }
@@ -0,0 +1,151 @@
+package goentdb
+
+import (
+	"strings"
+	"sync"
+)
+
+/*
+ISO 639-1 code of the language of the titles, selects the EntAnalyzer
+*/
+type EntLanguage string
+
+const (
+	LanguageEnglish EntLanguage = "en"
+	LanguageGerman  EntLanguage = "de"
+	LanguageFrench  EntLanguage = "fr"
+	LanguageSpanish EntLanguage = "es"
+	LanguageRussian EntLanguage = "ru"
+
+	DefaultLanguage = LanguageEnglish
+)
+
+/*
+Turns text into search terms: the tokens of Tokenizer which are at least
+3 letters long and no stop words, stemmed by Stemmer.
+Videos are indexed and queries are looked up with the analyzer of the
+language of the EntDB, so it has to be registered before videos are added.
+*/
+type EntAnalyzer struct {
+	StopWords map[string]bool
+	Stemmer   EntStemmer // nil keeps the tokens as they are
+}
+
+var (
+	analyzersLock sync.RWMutex
+	analyzers     = map[EntLanguage]*EntAnalyzer{
+		LanguageEnglish: {StopWords: StopWordsMap, Stemmer: PorterStemmer{}},
+		LanguageGerman:  {StopWords: stopWordSet(StopWordsGerman)},
+		LanguageFrench:  {StopWords: stopWordSet(StopWordsFrench)},
+		LanguageSpanish: {StopWords: stopWordSet(StopWordsSpanish)},
+		LanguageRussian: {StopWords: stopWordSet(StopWordsRussian)},
+	}
+	plainAnalyzer = &EntAnalyzer{StopWords: make(map[string]bool)}
+)
+
+/*
+Register analyzer for language, replaces an analyzer registered before
+*/
+func RegisterAnalyzer(language EntLanguage, analyzer *EntAnalyzer) {
+	analyzersLock.Lock()
+	defer analyzersLock.Unlock()
+
+	analyzers[language] = analyzer
+}
+
+/*
+Analyzer of language, the one of DefaultLanguage for an empty language.
+Languages without an analyzer keep every token as it is.
+*/
+func GetAnalyzer(language EntLanguage) *EntAnalyzer {
+	if language == "" {
+		language = DefaultLanguage
+	}
+
+	analyzersLock.RLock()
+	defer analyzersLock.RUnlock()
+
+	if analyzer, exists := analyzers[language]; exists {
+		return analyzer
+	}
+	return plainAnalyzer
+}
+
+/*
+Search terms of text in the order of their tokens, repeats included
+*/
+func (a *EntAnalyzer) Terms(text string) []string {
+	res := make([]string, 0)
+	a.analyze(text, func(token string, term string) {
+		res = append(res, term)
+	})
+	return res
+}
+
+func (a *EntAnalyzer) analyze(text string, fn func(token string, term string)) {
+	for _, token := range Tokenizer.Tokens(text) {
+		if isSearchToken(token) && !a.StopWords[token] {
+			fn(token, a.Stem(token))
+		}
+	}
+}
+
+func (a *EntAnalyzer) Stem(token string) string {
+	if a.Stemmer == nil {
+		return token
+	}
+	return a.Stemmer.Stem(token)
+}
+
+/*
+Terms of a query as they are looked up in the Search index, repeats dropped
+*/
+func (a *EntAnalyzer) queryTerms(Query string) []string {
+	seen := make(map[string]bool)
+	res := make([]string, 0)
+
+	for _, term := range a.Terms(Query) {
+		if !seen[term] {
+			seen[term] = true
+			res = append(res, term)
+		}
+	}
+
+	return res
+}
+
+/*
+Set of stop words as the tokenizer folds them, "don't" is kept as "dont" too
+*/
+func stopWordSet(words []string) map[string]bool {
+	res := make(map[string]bool, len(words))
+	for _, word := range words {
+		res[word] = true
+		res[strings.Join(Tokenizer.Tokens(word), "")] = true
+	}
+	return res
+}
+
+/*
+Analyzer of the Language of the EntDB
+*/
+func (edb *EntDB) Analyzer() *EntAnalyzer {
+	return GetAnalyzer(edb.Language)
+}
+
+/*
+Analyzer the videos of the index were indexed with
+*/
+func (idx *EntIndex) Analyzer() *EntAnalyzer {
+	return GetAnalyzer(idx.language)
+}
+
+/*
+Analyzer of the EntDB the video belongs to
+*/
+func (v *EntVideo) analyzer() *EntAnalyzer {
+	if v.Owner == nil {
+		return GetAnalyzer(DefaultLanguage)
+	}
+	return v.Owner.Analyzer()
+}
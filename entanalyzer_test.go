@@ -0,0 +1,103 @@
+package goentdb
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEntAnalyzerTerms(t *testing.T) {
+	cases := []struct {
+		Language EntLanguage
+		Text     string
+		Expected []string
+	}{
+		{Language: LanguageEnglish, Text: "The girls are running on the beach", Expected: []string{"girl", "run", "beach"}},
+		{Language: "", Text: "Don't stop", Expected: []string{"stop"}},
+		{Language: LanguageGerman, Text: "Die schönen Mädchen und der Strand", Expected: []string{"schönen", "mädchen", "strand"}},
+		{Language: LanguageRussian, Text: "Девушки и пляж, это лето", Expected: []string{"девушки", "пляж", "лето"}},
+		{Language: "ja", Text: "the beach", Expected: []string{"the", "beach"}},
+	}
+
+	for _, c := range cases {
+		Got := GetAnalyzer(c.Language).Terms(c.Text)
+		if strings.Join(Got, "|") != strings.Join(c.Expected, "|") {
+			t.Errorf("test analyzer %q terms failed: got %v, wanted %v", c.Language, Got, c.Expected)
+		}
+	}
+}
+
+func TestStopWords(t *testing.T) {
+	// Every listed word is in the map, in the form the tokenizer leaves it in
+	for _, word := range StopWords {
+		if !StopWordsMap[word] || !StopWordsMap[strings.Join(Tokenizer.Tokens(word), "")] {
+			t.Errorf("test stop words failed: %q is not in StopWordsMap", word)
+		}
+	}
+}
+
+func TestEntDBSearchStemmed(t *testing.T) {
+	entdb := GenerateSearchEntDB(t.TempDir(), []string{
+		"girl on the beach",
+		"two girls running",
+		"the runner",
+	})
+
+	cases := []struct {
+		Query    string
+		Expected []uint
+	}{
+		{Query: "girls", Expected: []uint{1, 2}},
+		{Query: "girl", Expected: []uint{1, 2}},
+		{Query: "runs", Expected: []uint{2}},
+		{Query: "\"girl\"", Expected: []uint{1}},
+		{Query: "the", Expected: []uint{}},
+	}
+
+	for _, c := range cases {
+		Got, _ := entdb.SearchRanked(c.Query, 10)
+		if len(Got) != len(c.Expected) {
+			t.Errorf("test stemmed search %q failed: got %v, wanted %v", c.Query, Got, c.Expected)
+			continue
+		}
+		for i, result := range Got {
+			if result.Video.Id != c.Expected[i] {
+				t.Errorf("test stemmed search %q failed: got %v, wanted %v", c.Query, result.Video.Id, c.Expected[i])
+			}
+		}
+	}
+
+	// Suggestions are the words as typed
+	if Got := entdb.Suggest("gi", 10).Tokens; len(Got) != 2 || Got[0].Phrase != "girl" || Got[1].Phrase != "girls" {
+		t.Errorf("test stemmed suggest failed: got %v", Got)
+	}
+}
+
+func TestEntDBLanguage(t *testing.T) {
+	entdb := NewEntDB(t.TempDir())
+	entdb.Language = LanguageGerman
+
+	for i, title := range []string{"Die Mädchen am Strand", "Der Strand im Sommer"} {
+		video := NewEntVideo(entdb)
+		video.Id = uint(i + 1)
+		video.Title = title
+		video.Slug = video.GetSlug()
+		entdb.Add(video)
+	}
+
+	if _, exists := entdb.Search["die"]; exists {
+		t.Errorf("test language stop words should not be indexed")
+	}
+
+	if _, total := entdb.SearchRanked("der strand", 10); total != 2 {
+		t.Errorf("test language search failed: got %v, wanted %v", total, 2)
+	}
+
+	// German words are not stemmed as English ones
+	if _, total := entdb.SearchRanked("sommers", 10); total != 0 {
+		t.Errorf("test language stemming failed: got %v, wanted %v", total, 0)
+	}
+
+	if Got := entdb.Index().Analyzer(); Got != GetAnalyzer(LanguageGerman) {
+		t.Errorf("test index analyzer failed: got %v, wanted %v", Got, GetAnalyzer(LanguageGerman))
+	}
+}
@@ -0,0 +1,107 @@
+package goentdb
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sync"
+)
+
+/*
+Id of the compression codec, stored in the header of every snapshot file
+*/
+type EntCodecId uint8
+
+const (
+	CodecNone EntCodecId = 0
+	CodecGzip EntCodecId = 1
+	// No implementation is bundled to keep the package free of third-party dependencies,
+	// register one with RegisterCodec (e.g. on top of github.com/klauspost/compress/zstd)
+	CodecZstd EntCodecId = 2
+)
+
+/*
+Compression of snapshot payloads, the file header is never compressed
+*/
+type EntCodec interface {
+	Id() EntCodecId
+	Name() string
+	NewWriter(w io.Writer) (io.WriteCloser, error)
+	NewReader(r io.Reader) (io.ReadCloser, error)
+}
+
+var (
+	codecsLock sync.RWMutex
+	codecs     = map[EntCodecId]EntCodec{
+		CodecNone: noneCodec{},
+		CodecGzip: GzipCodec{Level: gzip.DefaultCompression},
+	}
+)
+
+/*
+Register codec under its Id, replaces a codec registered before
+*/
+func RegisterCodec(codec EntCodec) {
+	codecsLock.Lock()
+	defer codecsLock.Unlock()
+
+	codecs[codec.Id()] = codec
+}
+
+func GetCodec(id EntCodecId) (EntCodec, error) {
+	codecsLock.RLock()
+	defer codecsLock.RUnlock()
+
+	codec, exists := codecs[id]
+	if !exists {
+		return nil, fmt.Errorf("codec %d is not registered", id)
+	}
+
+	return codec, nil
+}
+
+type noneCodec struct{}
+
+func (noneCodec) Id() EntCodecId {
+	return CodecNone
+}
+
+func (noneCodec) Name() string {
+	return "none"
+}
+
+func (noneCodec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return nopWriteCloser{w}, nil
+}
+
+func (noneCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return io.NopCloser(r), nil
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+type GzipCodec struct {
+	Level int
+}
+
+func (GzipCodec) Id() EntCodecId {
+	return CodecGzip
+}
+
+func (GzipCodec) Name() string {
+	return "gzip"
+}
+
+func (c GzipCodec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriterLevel(w, c.Level)
+}
+
+func (GzipCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
@@ -0,0 +1,159 @@
+package goentdb
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+/*
+Videos with the long repeated URL prefixes and descriptions of a real feed
+*/
+func GenerateFeedVideosForLoad(n int) []EntVideoForLoad {
+	items := make([]EntVideoForLoad, n)
+	for i := range items {
+		id := i + 1
+		items[i] = EntVideoForLoad{
+			Id:        uint(id),
+			Title:     fmt.Sprintf("title number %d", id),
+			Slug:      fmt.Sprintf("title-number-%d", id),
+			Origin:    OriginEporner,
+			OriginId:  fmt.Sprintf("%08d", id),
+			OriginUrl: fmt.Sprintf("https://www.eporner.com/video-%08d/", id),
+			Duration:  60 + id%600,
+			Descr:     fmt.Sprintf("description of the video number %d, uploaded by the channel of the origin", id),
+			ThumbUrls: []string{
+				fmt.Sprintf("https://static-ca-cdn.eporner.com/thumbs/static4/%d/1.jpg", id),
+				fmt.Sprintf("https://static-ca-cdn.eporner.com/thumbs/static4/%d/2.jpg", id),
+			},
+			VideoUrls: []string{
+				fmt.Sprintf("https://www.eporner.com/dload/%d/720p.mp4", id),
+			},
+		}
+	}
+	return items
+}
+
+func TestEntFileCodecRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	items := GenerateFeedVideosForLoad(500)
+
+	sizes := map[EntCodecId]int64{}
+	for _, codec := range []EntCodecId{CodecNone, CodecGzip} {
+		path := filepath.Join(dir, fmt.Sprintf("videos.%d", codec))
+
+		checksum, err := EncodeEntVideos(path, codec, len(items), func(pos int) EntVideoForLoad {
+			return items[pos]
+		})
+		if err != nil {
+			t.Fatalf("test encode with codec %d failed: %v", codec, err)
+		}
+		sizes[codec] = checksum.Size
+
+		Got := 0
+		hdr, err := DecodeEntVideos(path, func(evfl *EntVideoForLoad) error {
+			if evfl.OriginUrl != items[Got].OriginUrl {
+				t.Errorf("test decode with codec %d failed: got %v, wanted %v", codec, evfl.OriginUrl, items[Got].OriginUrl)
+			}
+			Got++
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("test decode with codec %d failed: %v", codec, err)
+		}
+		if hdr.Codec != codec {
+			t.Errorf("test header codec failed: got %v, wanted %v", hdr.Codec, codec)
+		}
+		if Got != len(items) {
+			t.Errorf("test decode count with codec %d failed: got %v, wanted %v", codec, Got, len(items))
+		}
+	}
+
+	if sizes[CodecGzip] >= sizes[CodecNone] {
+		t.Errorf("test gzip should be smaller: got %v, wanted less than %v", sizes[CodecGzip], sizes[CodecNone])
+	}
+}
+
+func TestEntDBDumpCompressed(t *testing.T) {
+	path := t.TempDir()
+	entdb := GenerateSnapshotEntDB(path)
+	entdb.Codec = CodecGzip
+
+	if err := entdb.Dump(); err != nil {
+		t.Fatalf("test dump failed: %v", err)
+	}
+
+	// Codec is detected from the file header
+	loaded := NewEntDB(path)
+	if err := loaded.Load(); err != nil {
+		t.Fatalf("test load failed: %v", err)
+	}
+
+	Expected := len(entdb.Items)
+	Got := len(loaded.Items)
+	if Got != Expected {
+		t.Errorf("test load compressed videos failed: got %v, wanted %v", Got, Expected)
+	}
+}
+
+func TestEntFileUnknownCodec(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tags")
+
+	f, _ := os.Create(path)
+	WriteEntFileHeader(f, EntFileHeader{Version: EntFormatVersion, Kind: EntFileKeywords, Codec: CodecZstd})
+	f.Close()
+
+	dict := map[int]*EntKeyword{}
+	_, err := DecodeEntFile(path, EntFileKeywords, &dict)
+	if err == nil || !strings.Contains(err.Error(), "not registered") {
+		t.Errorf("test unknown codec should be refused: got %v", err)
+	}
+}
+
+func benchmarkEntVideos(b *testing.B, codec EntCodecId, decode bool) {
+	path := filepath.Join(b.TempDir(), "videos")
+	items := GenerateFeedVideosForLoad(10000)
+
+	encode := func() FileChecksum {
+		checksum, err := EncodeEntVideos(path, codec, len(items), func(pos int) EntVideoForLoad {
+			return items[pos]
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+		return checksum
+	}
+
+	checksum := encode()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if !decode {
+			encode()
+			continue
+		}
+		if _, err := DecodeEntVideos(path, func(evfl *EntVideoForLoad) error { return nil }); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.ReportMetric(float64(checksum.Size), "bytes/file")
+}
+
+func BenchmarkDumpVideosNone(b *testing.B) {
+	benchmarkEntVideos(b, CodecNone, false)
+}
+
+func BenchmarkDumpVideosGzip(b *testing.B) {
+	benchmarkEntVideos(b, CodecGzip, false)
+}
+
+func BenchmarkLoadVideosNone(b *testing.B) {
+	benchmarkEntVideos(b, CodecNone, true)
+}
+
+func BenchmarkLoadVideosGzip(b *testing.B) {
+	benchmarkEntVideos(b, CodecGzip, true)
+}
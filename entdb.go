@@ -3,13 +3,12 @@ package goentdb
 import (
 	"errors"
 	"fmt"
-	"html"
-	"log"
 	"math/rand"
 	"os"
 	"sort"
-	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 //Запилить структуру для загрузки данных
@@ -19,8 +18,8 @@ type EntDB struct {
 	StoragePath  string
 	Items        []*EntVideo
 	SeoPool      []*EntKeyword
-	Tags         map[string][]*EntVideo
-	Models       map[string][]*EntVideo
+	Tags         map[string][]*EntVideo // Alias slugs share the slice of their canonical slug, read only
+	Models       map[string][]*EntVideo // Alias slugs share the slice of their canonical slug, read only
 	Search       map[string][]*EntVideo
 	Keywords     map[string]*EntVideo
 	DictTags     map[int]*EntKeyword
@@ -29,8 +28,30 @@ type EntDB struct {
 	TwoGrams     map[string][]*EntVideo
 	ThreeGrams   map[string][]*EntVideo
 	lock         sync.RWMutex
+	dumpLock     sync.Mutex
 	Origins      map[Origin]int
 	ThumbBaseUrl string
+	WALThreshold int64 // Compact WAL into a snapshot once it grows past this size, 0 disables
+	LoadPolicy   EntLoadPolicy
+	Codec        EntCodecId         // Compression of snapshot files written by Dump
+	ReloadCheck  EntReloadValidator // Extra checks of a snapshot before Reload swaps it in
+	Ranking      EntRanking         // BM25 parameters and field boosts of text search
+	Language     EntLanguage        // Selects the EntAnalyzer of text search, set it before videos are added
+	CursorTTL    time.Duration      // How long listing cursors keep their index generation, 0 uses DefaultCursorTTL
+	CursorPins   int                // Index generations kept for listing cursors at most, 0 uses DefaultCursorPins
+	wal          *EntWAL
+	index        atomic.Value // *EntIndex published for readers
+	next         *EntIndex    // Generation built by writers, guarded by lock
+	dirty        bool         // next has changes which are not published yet
+	previous     *EntDB       // State replaced by the last Reload, kept for Rollback
+	stamp        string       // Snapshot in StoragePath the state comes from, guarded by dumpLock
+	compactErr   error        // Failure of the last compaction started by a mutation, guarded by dumpLock
+	synonyms     entSynonyms  // Compiled by SetSynonyms, guarded by lock
+	redirects    entRedirects // Old slugs of renamed and merged keywords, guarded by lock
+	generation   uint64       // Snapshot generation the state is based on, stamped on WAL records, guarded by lock
+	keywordEdits uint64       // Changes of DictTags and DictModels, import resolvers refresh on a change, guarded by lock
+	pinLock      sync.Mutex
+	pins         map[uint64]*entPin // Index generations listing cursors point into, guarded by pinLock
 }
 
 func (edb *EntDB) GetDictTagsPath() string {
@@ -45,51 +66,78 @@ func (edb *EntDB) GetDictVideosPath() string {
 	return fmt.Sprintf("%s/videos", edb.StoragePath)
 }
 
-func (edb *EntDB) GetTagById(id int) (*EntKeyword, error) {
-	edb.lock.RLock()
-	defer edb.lock.RUnlock()
+func (edb *EntDB) GetWALPath() string {
+	return fmt.Sprintf("%s/wal", edb.StoragePath)
+}
 
-	if tag, exists := edb.DictTags[id]; exists {
+func (edb *EntDB) GetTagById(id int) (*EntKeyword, error) {
+	if tag, exists := edb.Index().Tag(id); exists {
 		return tag, nil
 	}
 
-	return nil, fmt.Errorf("EntKeyword(Tag) not found: %d", id)
+	return nil, notFound(EntKindTag, id)
 }
 
 func (edb *EntDB) GetModelById(id int) (*EntKeyword, error) {
-	edb.lock.RLock()
-	defer edb.lock.RUnlock()
-
-	if model, exists := edb.DictModels[id]; exists {
+	if model, exists := edb.Index().Model(id); exists {
 		return model, nil
 	}
 
-	return nil, fmt.Errorf("EntKeyword(Model) not found: %d", id)
+	return nil, notFound(EntKindModel, id)
 }
 
 func (edb *EntDB) GetVideoById(id uint) (*EntVideo, error) {
-	edb.lock.RLock()
-	defer edb.lock.RUnlock()
-
-	if video, exists := edb.DictVideos[id]; exists {
+	if video, exists := edb.Index().Video(id); exists {
 		return video, nil
 	}
 
-	return nil, fmt.Errorf("EntVideo not found: %d", id)
+	return nil, notFound(EntKindVideo, id)
 }
 
-func (edb *EntDB) AddTag(tag *EntKeyword) {
-	edb.lock.Lock()
-	defer edb.lock.Unlock()
+/*
+Add tag or replace the tag with the same id
+*/
+func (edb *EntDB) AddTag(tag *EntKeyword) error {
+	return edb.mutate(func() error {
+		return edb.addTag(tag)
+	})
+}
+
+/*
+Add model or replace the model with the same id
+*/
+func (edb *EntDB) AddModel(model *EntKeyword) error {
+	return edb.mutate(func() error {
+		return edb.addModel(model)
+	})
+}
+
+func (edb *EntDB) addTag(tag *EntKeyword) error {
+	if err := edb.logMutation(&EntWALRecord{Op: EntWALAddTag, Keyword: tag}); err != nil {
+		return err
+	}
 
+	if old, exists := edb.DictTags[tag.Id]; exists {
+		edb.next.tagTrie.set(suggestKey(old.Phrase), nil)
+	}
 	edb.DictTags[tag.Id] = tag
+	edb.indexTag(tag.Id)
+
+	return nil
 }
 
-func (edb *EntDB) AddModel(model *EntKeyword) {
-	edb.lock.Lock()
-	defer edb.lock.Unlock()
+func (edb *EntDB) addModel(model *EntKeyword) error {
+	if err := edb.logMutation(&EntWALRecord{Op: EntWALAddModel, Keyword: model}); err != nil {
+		return err
+	}
 
+	if old, exists := edb.DictModels[model.Id]; exists {
+		edb.next.modelTrie.set(suggestKey(old.Phrase), nil)
+	}
 	edb.DictModels[model.Id] = model
+	edb.indexModel(model.Id)
+
+	return nil
 }
 
 /*
@@ -98,17 +146,121 @@ Add video to the DB
 - Add to tag/model -> []*EntVideo for tag/model slices
 - Add to keyword -> *EntVideos map for original slug md5 and keyword slug md5 access
 */
-func (edb *EntDB) Add(video *EntVideo) {
-	edb.lock.Lock()
-	defer edb.lock.Unlock()
+func (edb *EntDB) Add(video *EntVideo) error {
+	return edb.mutate(func() error {
+		if _, exists := edb.DictVideos[video.Id]; exists {
+			return duplicate(EntKindVideo, video.Id)
+		}
+
+		if err := edb.logVideoMutation(EntWALAddVideo, video); err != nil {
+			return err
+		}
+
+		edb.add(video)
+
+		return nil
+	})
+}
+
+/*
+Add videos as one batch, readers see the whole batch at once.
+Nothing is added and nothing is logged when the WAL write fails.
+Bulk ingestion should prefer it over Add: the index generation is built
+and published once instead of once per video.
+*/
+func (edb *EntDB) AddBatch(videos []*EntVideo) error {
+	return edb.mutate(func() error {
+		// Nothing is added when any video is a duplicate
+		seen := make(map[uint]bool, len(videos))
+		for _, video := range videos {
+			if _, exists := edb.DictVideos[video.Id]; exists || seen[video.Id] {
+				return duplicate(EntKindVideo, video.Id)
+			}
+			seen[video.Id] = true
+		}
+
+		// Whole batch is logged before any video is added
+		if edb.wal != nil {
+			recs := make([]*EntWALRecord, len(videos))
+			for pos, video := range videos {
+				recs[pos] = videoRecord(EntWALAddVideo, video)
+			}
+			if err := edb.logMutation(recs...); err != nil {
+				return err
+			}
+		}
+
+		for _, video := range videos {
+			edb.add(video)
+		}
+
+		return nil
+	})
+}
+
+/*
+Remove video from the DB
+Every index entry created by Add is dropped as well
+*/
+func (edb *EntDB) Remove(id uint) error {
+	return edb.mutate(func() error {
+		video, exists := edb.DictVideos[id]
+		if !exists {
+			return notFound(EntKindVideo, id)
+		}
+
+		if err := edb.logMutation(&EntWALRecord{Op: EntWALRemoveVideo, Id: id}); err != nil {
+			return err
+		}
+
+		edb.remove(video)
+
+		return nil
+	})
+}
+
+/*
+Stored videos are never changed in place, the index keys of the old version
+are computed from its fields when it is replaced
+*/
+var ErrVideoInPlace = errors.New("video is the stored value, pass a changed copy")
+
+/*
+Update replaces the stored video with the same Id
+Index entries of the old version are dropped before the new version is indexed.
+video must be a new value, passing the stored one returns ErrVideoInPlace.
+*/
+func (edb *EntDB) Update(video *EntVideo) error {
+	return edb.mutate(func() error {
+		old, exists := edb.DictVideos[video.Id]
+		if !exists {
+			return notFound(EntKindVideo, video.Id)
+		}
+		if old == video {
+			return ErrVideoInPlace
+		}
+
+		if err := edb.logVideoMutation(EntWALUpdateVideo, video); err != nil {
+			return err
+		}
+
+		edb.remove(old)
+		edb.add(video)
 
+		return nil
+	})
+}
+
+func (edb *EntDB) add(video *EntVideo) {
 	video.Owner = edb
 	edb.Items = append(edb.Items, video)
-	for _, tag := range video.Tags {
-		edb.Tags[tag.GetSlug()] = append(edb.Tags[tag.GetSlug()], video)
+	for _, slug := range edb.synonyms.tags.slugs(video.Tags) {
+		edb.Tags[slug] = append(edb.Tags[slug], video)
+		edb.synonyms.tags.link(edb.Tags, slug)
 	}
-	for _, model := range video.Models {
-		edb.Models[model.GetSlug()] = append(edb.Models[model.GetSlug()], video)
+	for _, slug := range edb.synonyms.models.slugs(video.Models) {
+		edb.Models[slug] = append(edb.Models[slug], video)
+		edb.synonyms.models.link(edb.Models, slug)
 	}
 
 	// Add original slug to map for O(1) access for video
@@ -121,20 +273,62 @@ func (edb *EntDB) Add(video *EntVideo) {
 	edb.DictVideos[video.Id] = video
 	edb.Origins[video.Origin]++
 
-	title := strings.ToLower(video.Title)
-	title = strings.Replace(title, "-", " ", -1)
-	tokens := strings.Split(title, " ")
-
-	for _, token := range tokens {
-		token = strings.Trim(token, TrimSymbols)
-		if len(token) < 3 {
-			continue
-		}
+	for _, token := range video.GetSearchTokens() {
 		videos := edb.Search[token]
 		edb.Search[token] = append(videos, video)
 	}
 
 	edb.IndexNGrams(video, false)
+	edb.next.countFields(video, 1)
+	edb.suggestTokens(video, 1)
+	edb.indexVideo(video)
+}
+
+func (edb *EntDB) remove(video *EntVideo) {
+	edb.Items = RemoveVideo(edb.Items, video)
+
+	for _, slug := range edb.synonyms.tags.slugs(video.Tags) {
+		RemoveVideoFromIndex(edb.Tags, slug, video)
+		edb.synonyms.tags.link(edb.Tags, slug)
+	}
+	for _, slug := range edb.synonyms.models.slugs(video.Models) {
+		RemoveVideoFromIndex(edb.Models, slug, video)
+		edb.synonyms.models.link(edb.Models, slug)
+	}
+
+	if edb.Keywords[video.GetMD5()] == video {
+		delete(edb.Keywords, video.GetMD5())
+	}
+	for _, keyword := range video.Keywords {
+		if edb.Keywords[keyword.GetMD5()] == video {
+			delete(edb.Keywords, keyword.GetMD5())
+		}
+	}
+
+	if edb.DictVideos[video.Id] == video {
+		delete(edb.DictVideos, video.Id)
+	}
+
+	edb.Origins[video.Origin]--
+	if edb.Origins[video.Origin] <= 0 {
+		delete(edb.Origins, video.Origin)
+	}
+
+	for _, token := range video.GetSearchTokens() {
+		RemoveVideoFromIndex(edb.Search, token, video)
+	}
+
+	TwoGrams, ThreeGrams := video.GetNGrams(false)
+	for _, gram := range TwoGrams {
+		RemoveVideoFromIndex(edb.TwoGrams, gram, video)
+	}
+	for _, gram := range ThreeGrams {
+		RemoveVideoFromIndex(edb.ThreeGrams, gram, video)
+	}
+
+	edb.next.countFields(video, -1)
+	edb.suggestTokens(video, -1)
+	edb.indexVideo(video)
 }
 
 func (edb *EntDB) IndexNGrams(video *EntVideo, excludeStopWords bool) {
@@ -155,7 +349,29 @@ func (edb *EntDB) IndexNGrams(video *EntVideo, excludeStopWords bool) {
 	}
 }
 
-func (edb *EntDB) AddVideoFromLoad(evfl *EntVideoForLoad) {
+/*
+Add video restored from a snapshot, the video is not written to the WAL
+*/
+func (edb *EntDB) AddVideoFromLoad(evfl *EntVideoForLoad) error {
+	edb.lock.Lock()
+	defer edb.lock.Unlock()
+	defer edb.publish()
+
+	if _, exists := edb.DictVideos[evfl.Id]; exists {
+		return duplicate(EntKindVideo, evfl.Id)
+	}
+
+	ev, err := edb.videoFromLoad(evfl)
+	if err != nil {
+		return err
+	}
+
+	edb.add(ev)
+
+	return nil
+}
+
+func (edb *EntDB) videoFromLoad(evfl *EntVideoForLoad) (*EntVideo, error) {
 	ev := NewEntVideo(edb)
 
 	ev.Id = evfl.Id
@@ -173,37 +389,33 @@ func (edb *EntDB) AddVideoFromLoad(evfl *EntVideoForLoad) {
 	ev.VideoUrls = evfl.VideoUrls
 
 	for _, tag_id := range evfl.Tags {
-		tag, err := edb.GetTagById(tag_id)
-		if err != nil {
-			log.Fatal(err)
+		tag, exists := edb.DictTags[tag_id]
+		if !exists {
+			return nil, notFound(EntKindTag, tag_id)
 		}
 		ev.AddTag(tag)
 	}
 
 	for _, model_id := range evfl.Models {
-		model, err := edb.GetModelById(model_id)
-		if err != nil {
-			log.Fatal(err)
+		model, exists := edb.DictModels[model_id]
+		if !exists {
+			return nil, notFound(EntKindModel, model_id)
 		}
 		ev.AddModel(model)
 	}
 
-	// edb.Items = append(edb.Items, ev)
-	edb.Add(ev)
+	return ev, nil
 }
 
 /*
 Get Video by original slug md5 or keyword slug md5
 */
 func (edb *EntDB) GetVideoByMD5(key string) (*EntVideo, error) {
-	edb.lock.RLock()
-	defer edb.lock.RUnlock()
-
-	if video, exists := edb.Keywords[key]; exists {
+	if video, exists := edb.Index().VideoByMD5(key); exists {
 		return video, nil
 	}
 
-	return nil, errors.New("EntVideo not found")
+	return nil, notFound(EntKindVideo, key)
 }
 
 func (edb *EntDB) GetKeywordsRelatedSet(Video *EntVideo, Size int, UseSeoPool bool, Exclude []*EntVideo) []*EntKeyword {
@@ -238,10 +450,15 @@ func (edb *EntDB) GetKeywordsRelatedSet(Video *EntVideo, Size int, UseSeoPool bo
 func (edb *EntDB) GetKeywordsRandomSet(Size int, UseSeoPool bool, Exclude []*EntVideo) []*EntKeyword {
 	res := make([]*EntKeyword, Size)
 
+	idx := edb.Index()
+	if idx.Len() == 0 {
+		return res[:0]
+	}
+
 	pos := 0
 
 	for pos < Size {
-		ev := edb.Random()
+		ev := idx.Random()
 		// TODO: if (ev not in Exclude and ev not in Taken) {
 		// Check if EntVideo has any associated EntKeyword
 		if len(ev.Keywords) > 0 {
@@ -255,42 +472,11 @@ func (edb *EntDB) GetKeywordsRandomSet(Size int, UseSeoPool bool, Exclude []*Ent
 }
 
 /*
-Get slice of random EntVideos based on query filter
+Get slice of EntVideos based on query filter, best ranked first
 */
 func (edb *EntDB) RandomSetBySearch(Query string, Size int) ([]*EntVideo, int) {
-	QueryTokens := strings.Split(strings.ToLower(Query), " ")
-	Counter := make(map[*EntVideo]int)
-
-	for _, Token := range QueryTokens {
-		if videos, exists := edb.Search[Token]; exists {
-			for _, video := range videos {
-				Counter[video]++
-			}
-		}
-	}
-
-	type KeyValue struct {
-		Video *EntVideo
-		Value int
-	}
-
-	var SortedSlice []KeyValue
-
-	for k, v := range Counter {
-		SortedSlice = append(SortedSlice, KeyValue{k, v})
-	}
-
-	sort.Slice(SortedSlice, func(i, j int) bool {
-		return SortedSlice[i].Value > SortedSlice[j].Value
-	})
-
-	res := make([]*EntVideo, Min(len(SortedSlice), Size))
-
-	for i := 0; i < Min(len(SortedSlice), Size); i++ {
-		res[i] = SortedSlice[i].Video
-	}
-
-	return res, len(SortedSlice)
+	results, total := edb.SearchRanked(Query, Size)
+	return searchResultVideos(results), total
 }
 
 /*
@@ -342,66 +528,11 @@ func (edb *EntDB) GetRelevantForVideo(Video *EntVideo, Size int) ([]*EntVideo, i
 }
 
 /*
-Get slice of random EntVideos based on query filter
+Get slice of EntVideos relevant to the words of the slug, best ranked first
 */
 func (edb *EntDB) RelevantBySearch(Slug string, Size int) ([]*EntVideo, int) {
-
-	Weights := map[string]int{
-		"video": 0,
-		"porn":  0,
-		"sex":   0,
-		"fuck":  0,
-		"xxx":   0,
-		"xnxx":  0,
-	}
-
-	QueryTokens := strings.Split(strings.ToLower(Slug), "-")
-	Counter := make(map[*EntVideo]int)
-
-	for _, token := range QueryTokens {
-		token = strings.Trim(token, TrimSymbols)
-
-		if _, exists := StopWordsMap[token]; exists {
-			continue
-		}
-
-		if len(token) < 3 {
-			continue
-		}
-
-		if videos, exists := edb.Search[token]; exists {
-			for _, video := range videos {
-				if weight, found := Weights[token]; found {
-					Counter[video] = Counter[video] + weight
-				} else {
-					Counter[video]++
-				}
-			}
-		}
-	}
-
-	type KeyValue struct {
-		Video *EntVideo
-		Value int
-	}
-
-	var SortedSlice []KeyValue
-
-	for k, v := range Counter {
-		SortedSlice = append(SortedSlice, KeyValue{k, v})
-	}
-
-	sort.Slice(SortedSlice, func(i, j int) bool {
-		return SortedSlice[i].Value > SortedSlice[j].Value
-	})
-
-	res := make([]*EntVideo, Min(len(SortedSlice), Size))
-
-	for i := 0; i < Min(len(SortedSlice), Size); i++ {
-		res[i] = SortedSlice[i].Video
-	}
-
-	return res, len(SortedSlice)
+	results, total := edb.SearchRanked(Slug, Size)
+	return searchResultVideos(results), total
 }
 
 /*
@@ -409,60 +540,14 @@ Get RelevantVideos for EntVideo
 Exclude MainVideo from the result
 */
 func (edb *EntDB) GetRelevantForVideoBySearch(Video *EntVideo, Size int) ([]*EntVideo, int) {
-	Title := html.UnescapeString(Video.Title)
-	// TODO: migrate to the slug algo
-	QueryTokens := strings.Split(strings.ToLower(Title), " ")
-	Counter := make(map[*EntVideo]int)
-
-	for _, token := range QueryTokens {
-		token = strings.Trim(token, TrimSymbols)
-
-		if _, exists := StopWordsMap[token]; exists {
-			continue
-		}
-
-		if len(token) < 3 {
-			continue
-		}
-
-		if videos, exists := edb.Search[token]; exists {
-			for _, video := range videos {
-				Counter[video]++
-			}
-		}
-	}
-
-	type KeyValue struct {
-		Video *EntVideo
-		Value int
-	}
-
-	var SortedSlice []KeyValue
-
-	for k, v := range Counter {
-		if k.Id == Video.Id {
-			continue
-		}
-		SortedSlice = append(SortedSlice, KeyValue{k, v})
-	}
-
-	sort.Slice(SortedSlice, func(i, j int) bool {
-		return SortedSlice[i].Value > SortedSlice[j].Value
-	})
-
-	res := make([]*EntVideo, Min(len(SortedSlice), Size))
-
-	for i := 0; i < Min(len(SortedSlice), Size); i++ {
-		res[i] = SortedSlice[i].Video
-	}
-
-	return res, len(SortedSlice)
+	results, total := edb.RelevantRanked(Video, Size)
+	return searchResultVideos(results), total
 }
 
 func (ev *EntDB) RandomSetByModel(ModelSlug string, Size int) ([]*EntVideo, int) {
-	models, exists := ev.Models[ModelSlug]
+	models := ev.Index().ByModel(ModelSlug)
 
-	if !exists {
+	if len(models) == 0 {
 		return make([]*EntVideo, 0), 0
 	}
 
@@ -470,7 +555,9 @@ func (ev *EntDB) RandomSetByModel(ModelSlug string, Size int) ([]*EntVideo, int)
 
 	// if expected size bigger than actual list then just take a list
 	if Size >= len(models) {
-		return models, len(models)
+		res := make([]*EntVideo, len(models))
+		copy(res, models)
+		return res, len(models)
 	}
 
 	seen := make(map[*EntVideo]bool)
@@ -494,16 +581,18 @@ func (ev *EntDB) RandomSetByModel(ModelSlug string, Size int) ([]*EntVideo, int)
 }
 
 func (ev *EntDB) RandomSetByTag(TagSlug string, Size int) ([]*EntVideo, int) {
-	tags, exists := ev.Tags[TagSlug]
+	tags := ev.Index().ByTag(TagSlug)
 
-	if !exists {
+	if len(tags) == 0 {
 		return make([]*EntVideo, 0), 0
 	}
 
 	var candidate *EntVideo
 
 	if Size >= len(tags) {
-		return tags, len(tags)
+		res := make([]*EntVideo, len(tags))
+		copy(res, tags)
+		return res, len(tags)
 	}
 
 	seen := make(map[*EntVideo]bool)
@@ -527,17 +616,16 @@ func (ev *EntDB) RandomSetByTag(TagSlug string, Size int) ([]*EntVideo, int) {
 func (edb *EntDB) RandomSet(Size int) []*EntVideo {
 	res := make([]*EntVideo, Size)
 
+	idx := edb.Index()
 	for i := 0; i < Size; i++ {
-		res[i] = edb.Random()
+		res[i] = idx.Random()
 	}
 
 	return res
 }
 
 func (edb *EntDB) Random() *EntVideo {
-	edb.lock.RLock()
-	defer edb.lock.RUnlock()
-	return edb.Items[rand.Intn(len(edb.Items))]
+	return edb.Index().Random()
 }
 
 /*
@@ -553,8 +641,13 @@ func (edb *EntDB) RandomKeywordSet(Size int, useSeoPool bool) []*EntKeyword {
 func (edb *EntDB) RandomKeywordSetFromGeneralPool(Size int) []*EntKeyword {
 	res := make([]*EntKeyword, 0)
 
+	idx := edb.Index()
+	if idx.Len() == 0 {
+		return res
+	}
+
 	for len(res) < Size {
-		kw := edb.Random().GetRandomKeyword()
+		kw := idx.Random().GetRandomKeyword()
 		if kw.Phrase != "not-found" {
 			res = append(res, kw)
 		}
@@ -585,7 +678,7 @@ func NewEntDB(path string) *EntDB {
 		os.MkdirAll(path, os.ModePerm)
 	}
 
-	return &EntDB{
+	edb := &EntDB{
 		StoragePath: path,
 		Tags:        make(map[string][]*EntVideo),
 		Models:      make(map[string][]*EntVideo),
@@ -597,5 +690,11 @@ func NewEntDB(path string) *EntDB {
 		Origins:     make(map[Origin]int),
 		TwoGrams:    make(map[string][]*EntVideo),
 		ThreeGrams:  make(map[string][]*EntVideo),
+		next:        newEntIndex(),
+		dirty:       true,
+		pins:        make(map[uint64]*entPin),
 	}
+	edb.publish()
+
+	return edb
 }
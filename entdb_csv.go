@@ -0,0 +1,428 @@
+package goentdb
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	CSVFieldId       = "id"
+	CSVFieldPhrase   = "phrase"
+	CSVFieldTitle    = "title"
+	CSVFieldOrigin   = "origin"
+	CSVFieldOriginId = "origin_id"
+	CSVFieldUrl      = "url"
+	CSVFieldDuration = "duration"
+	CSVFieldTags     = "tags"
+	CSVFieldModels   = "models"
+	CSVFieldKeywords = "keywords"
+)
+
+/*
+Options of the CSV importers
+Columns maps a field (CSVField*) to the header of the column holding it,
+fields which are not mapped are looked up by their own name.
+*/
+type EntCSVOptions struct {
+	Columns map[string]string
+	Comma   rune   // Field delimiter, ',' by default
+	ListSep string // Separator of tags/models/keywords inside a cell, "|" by default
+	DryRun  bool   // Only report what would change
+}
+
+type entCSVReader struct {
+	reader  *csv.Reader
+	columns map[string]int
+	listSep string
+}
+
+func newEntCSVReader(r io.Reader, opts EntCSVOptions, fields []string, required []string) (*entCSVReader, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	if opts.Comma != 0 {
+		reader.Comma = opts.Comma
+	}
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("csv header: %w", err)
+	}
+
+	position := make(map[string]int)
+	for pos, name := range header {
+		position[strings.ToLower(strings.TrimSpace(name))] = pos
+	}
+
+	cr := &entCSVReader{reader: reader, columns: make(map[string]int), listSep: opts.ListSep}
+	if cr.listSep == "" {
+		cr.listSep = "|"
+	}
+
+	for _, field := range fields {
+		name := field
+		if mapped, exists := opts.Columns[field]; exists {
+			name = mapped
+		}
+		if pos, exists := position[strings.ToLower(name)]; exists {
+			cr.columns[field] = pos
+		}
+	}
+
+	for _, field := range required {
+		if _, exists := cr.columns[field]; !exists {
+			return nil, fmt.Errorf("csv header has no column for %s", field)
+		}
+	}
+
+	return cr, nil
+}
+
+/*
+Next record and its line number, io.EOF at the end of input.
+Only a *csv.ParseError is a bad row the input continues after,
+any other error is a failure of the reader.
+*/
+func (cr *entCSVReader) next() ([]string, int, error) {
+	record, err := cr.reader.Read()
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return nil, parseErr.StartLine, err
+		}
+		return nil, 0, err
+	}
+
+	line, _ := cr.reader.FieldPos(0)
+
+	return record, line, nil
+}
+
+func badCSVRow(err error) bool {
+	var parseErr *csv.ParseError
+	return errors.As(err, &parseErr)
+}
+
+func (cr *entCSVReader) get(record []string, field string) string {
+	pos, exists := cr.columns[field]
+	if !exists || pos >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[pos])
+}
+
+/*
+Input has a column for field
+*/
+func (cr *entCSVReader) has(field string) bool {
+	_, exists := cr.columns[field]
+	return exists
+}
+
+func (cr *entCSVReader) list(record []string, field string) []string {
+	res := make([]string, 0)
+	for _, item := range strings.Split(cr.get(record, field), cr.listSep) {
+		if item = strings.TrimSpace(item); item != "" {
+			res = append(res, item)
+		}
+	}
+	return res
+}
+
+/*
+Import DictTags from CSV with id and phrase columns, id may be empty
+*/
+func (edb *EntDB) ImportTagsCSV(r io.Reader, opts EntCSVOptions) (*EntImportReport, error) {
+	return edb.importKeywordsCSV(r, opts, EntKeywordTag)
+}
+
+/*
+Import DictModels from CSV with id and phrase columns, id may be empty
+*/
+func (edb *EntDB) ImportModelsCSV(r io.Reader, opts EntCSVOptions) (*EntImportReport, error) {
+	return edb.importKeywordsCSV(r, opts, EntKeywordModel)
+}
+
+func (edb *EntDB) importKeywordsCSV(r io.Reader, opts EntCSVOptions, kind EntKeywordType) (*EntImportReport, error) {
+	cr, err := newEntCSVReader(r, opts, []string{CSVFieldId, CSVFieldPhrase}, []string{CSVFieldPhrase})
+	if err != nil {
+		return nil, err
+	}
+
+	report := &EntImportReport{DryRun: opts.DryRun}
+
+	edb.lock.Lock()
+	defer edb.lock.Unlock()
+	defer edb.publish()
+
+	resolver := edb.newKeywordResolver(kind, opts.DryRun)
+
+	for {
+		record, line, err := cr.next()
+		if err == io.EOF {
+			return report, nil
+		}
+		report.Lines++
+		if err != nil {
+			if !badCSVRow(err) {
+				return report, err
+			}
+			report.addError(line, err)
+			continue
+		}
+
+		if err := importKeywordRecord(cr, record, resolver, report); err != nil {
+			report.addError(line, err)
+		}
+	}
+}
+
+func importKeywordRecord(cr *entCSVReader, record []string, resolver *entKeywordResolver, report *EntImportReport) error {
+	phrase := cr.get(record, CSVFieldPhrase)
+	if phrase == "" {
+		return errors.New("phrase is required")
+	}
+
+	id := 0
+	if value := cr.get(record, CSVFieldId); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("bad id %q", value)
+		}
+		id = parsed
+	}
+
+	if existing, exists := resolver.get(id); exists && id != 0 {
+		if existing.Phrase != phrase {
+			return fmt.Errorf("%w as %q", duplicate(resolver.entKind(), id), existing.Phrase)
+		}
+		report.Unchanged++
+		return nil
+	}
+
+	keyword, created, err := resolver.resolve(id, phrase)
+	if err != nil {
+		return err
+	}
+
+	if !created {
+		report.Unchanged++
+		return nil
+	}
+
+	report.Added++
+	if keyword.Type == EntKeywordModel {
+		report.CreatedModels = append(report.CreatedModels, keyword)
+	} else {
+		report.CreatedTags = append(report.CreatedTags, keyword)
+	}
+
+	return nil
+}
+
+/*
+Import videos from CSV
+Columns: id, title, origin (name from OriginNames), origin_id, url, duration
+(seconds or hh:mm:ss), tags, models and keywords (phrases separated by ListSep).
+Unknown tag and model phrases are created. A video with a stored id is updated:
+only the columns of the input are overwritten and its slug is kept.
+*/
+func (edb *EntDB) ImportVideosCSV(r io.Reader, opts EntCSVOptions) (*EntImportReport, error) {
+	fields := []string{
+		CSVFieldId, CSVFieldTitle, CSVFieldOrigin, CSVFieldOriginId, CSVFieldUrl,
+		CSVFieldDuration, CSVFieldTags, CSVFieldModels, CSVFieldKeywords,
+	}
+	cr, err := newEntCSVReader(r, opts, fields, []string{CSVFieldId, CSVFieldTitle})
+	if err != nil {
+		return nil, err
+	}
+
+	report := &EntImportReport{DryRun: opts.DryRun}
+
+	edb.lock.Lock()
+	tags := edb.newKeywordResolver(EntKeywordTag, opts.DryRun)
+	models := edb.newKeywordResolver(EntKeywordModel, opts.DryRun)
+	edb.lock.Unlock()
+
+	for {
+		record, line, err := cr.next()
+		if err == io.EOF {
+			return report, nil
+		}
+		report.Lines++
+		if err != nil {
+			if !badCSVRow(err) {
+				return report, err
+			}
+			report.addError(line, err)
+			continue
+		}
+
+		if err := edb.importVideoRecord(cr, record, tags, models, report); err != nil {
+			report.addError(line, err)
+		}
+	}
+}
+
+func (edb *EntDB) importVideoRecord(cr *entCSVReader, record []string, tags, models *entKeywordResolver, report *EntImportReport) error {
+	id, err := strconv.ParseUint(cr.get(record, CSVFieldId), 10, 0)
+	if err != nil || id == 0 {
+		return fmt.Errorf("bad id %q", cr.get(record, CSVFieldId))
+	}
+
+	edb.lock.RLock()
+	existing, exists := edb.DictVideos[uint(id)]
+	edb.lock.RUnlock()
+
+	// An update starts from a copy of the stored video: columns missing from
+	// the input keep their values and the slug, the key of its URL, is kept
+	video := NewEntVideo(edb)
+	if exists {
+		stored := *existing
+		video = &stored
+	}
+	video.Id = uint(id)
+	video.Title = cr.get(record, CSVFieldTitle)
+	if cr.has(CSVFieldOriginId) {
+		video.OriginId = cr.get(record, CSVFieldOriginId)
+	}
+	if cr.has(CSVFieldUrl) {
+		video.OriginUrl = cr.get(record, CSVFieldUrl)
+	}
+	if !exists {
+		video.Slug = video.GetSlug()
+	}
+
+	if video.Title == "" {
+		return errors.New("title is required")
+	}
+
+	if cr.has(CSVFieldOrigin) {
+		video.Origin = OriginUnkown
+		if name := cr.get(record, CSVFieldOrigin); name != "" {
+			origin, exists := OriginByName(name)
+			if !exists {
+				return fmt.Errorf("unknown origin %q", name)
+			}
+			video.Origin = origin
+		}
+	}
+
+	if cr.has(CSVFieldDuration) {
+		video.Duration = 0
+		if value := cr.get(record, CSVFieldDuration); value != "" {
+			duration, err := ParseDuration(value)
+			if err != nil {
+				return err
+			}
+			video.Duration = duration
+		}
+	}
+
+	// Lists of the copy are replaced, never appended to, they are shared with the stored video
+	if cr.has(CSVFieldKeywords) {
+		video.Keywords = make([]*EntKeyword, 0)
+		video.MapKeyword = make(map[string]*EntKeyword)
+		for _, phrase := range cr.list(record, CSVFieldKeywords) {
+			video.AddKeyword(NewKeyword(0, phrase))
+		}
+	}
+	if cr.has(CSVFieldTags) {
+		video.Tags = make([]*EntKeyword, 0)
+	}
+	if cr.has(CSVFieldModels) {
+		video.Models = make([]*EntKeyword, 0)
+	}
+
+	tagList := make([]EntKeywordJSON, 0)
+	for _, phrase := range cr.list(record, CSVFieldTags) {
+		tagList = append(tagList, EntKeywordJSON{Phrase: phrase})
+	}
+	modelList := make([]EntKeywordJSON, 0)
+	for _, phrase := range cr.list(record, CSVFieldModels) {
+		modelList = append(modelList, EntKeywordJSON{Phrase: phrase})
+	}
+
+	edb.lock.Lock()
+	err = edb.resolveKeywords(video, tagList, modelList, tags, models, report)
+	edb.publish()
+	edb.lock.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if exists && sameVideoContent(existing, video) {
+		report.Unchanged++
+		return nil
+	}
+
+	if report.DryRun {
+		if exists {
+			report.Updated++
+		} else {
+			report.Added++
+		}
+		return nil
+	}
+
+	added, err := edb.upsert(video)
+	if err != nil {
+		return err
+	}
+	if added {
+		report.Added++
+	} else {
+		report.Updated++
+	}
+
+	return nil
+}
+
+/*
+Parse duration given in seconds or as [hh:]mm:ss
+*/
+func ParseDuration(value string) (int, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("bad duration %q", value)
+	}
+
+	duration := 0
+	for _, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("bad duration %q", value)
+		}
+		duration = duration*60 + n
+	}
+
+	return duration, nil
+}
+
+/*
+Compare fields covered by the CSV importer
+*/
+func sameVideoContent(a, b *EntVideo) bool {
+	if a.Title != b.Title || a.Origin != b.Origin || a.OriginId != b.OriginId ||
+		a.OriginUrl != b.OriginUrl || a.Duration != b.Duration {
+		return false
+	}
+
+	phrases := func(keywords []*EntKeyword) string {
+		res := make([]string, len(keywords))
+		for pos, keyword := range keywords {
+			res[pos] = keyword.GetSlug()
+		}
+		sort.Strings(res)
+		return strings.Join(res, ",")
+	}
+
+	return phrases(a.Tags) == phrases(b.Tags) &&
+		phrases(a.Models) == phrases(b.Models) &&
+		phrases(a.Keywords) == phrases(b.Keywords)
+}
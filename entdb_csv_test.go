@@ -0,0 +1,195 @@
+package goentdb
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestEntDBImportTagsCSV(t *testing.T) {
+	entdb := NewEntDB(t.TempDir())
+	entdb.AddTag(NewTag(1, "blonde"))
+
+	input := strings.Join([]string{
+		"Tag Id;Name",
+		"1;blonde",
+		"2;brunette",
+		";redhead",
+		"1;blond",
+		"x;bad",
+		"3;",
+	}, "\n")
+
+	opts := EntCSVOptions{
+		Columns: map[string]string{CSVFieldId: "tag id", CSVFieldPhrase: "name"},
+		Comma:   ';',
+		DryRun:  true,
+	}
+
+	report, err := entdb.ImportTagsCSV(strings.NewReader(input), opts)
+	if err != nil {
+		t.Fatalf("test import tags csv failed: %v", err)
+	}
+
+	if report.Added != 2 || report.Unchanged != 1 || len(report.Errors) != 3 {
+		t.Errorf("test import tags csv dry run failed: got %d/%d/%v", report.Added, report.Unchanged, report.Errors)
+	}
+
+	ExpectedLines := []int{5, 6, 7}
+	for pos, lineErr := range report.Errors {
+		if lineErr.Line != ExpectedLines[pos] {
+			t.Errorf("test import tags csv error line failed: got %v, wanted %v", lineErr.Line, ExpectedLines[pos])
+		}
+	}
+
+	Expected := 1
+	Got := len(entdb.DictTags)
+	if Got != Expected {
+		t.Errorf("test import tags csv dry run should not change tags: got %v, wanted %v", Got, Expected)
+	}
+
+	opts.DryRun = false
+	report, err = entdb.ImportTagsCSV(strings.NewReader(input), opts)
+	if err != nil {
+		t.Fatalf("test import tags csv failed: %v", err)
+	}
+
+	Expected = 3
+	Got = len(entdb.DictTags)
+	if Got != Expected {
+		t.Errorf("test import tags csv count failed: got %v, wanted %v", Got, Expected)
+	}
+
+	if entdb.DictTags[3] == nil || entdb.DictTags[3].Phrase != "redhead" {
+		t.Errorf("test import tags csv next id failed: got %v", entdb.DictTags[3])
+	}
+}
+
+func TestEntDBImportVideosCSV(t *testing.T) {
+	entdb := NewEntDB(t.TempDir())
+	entdb.ImportModelsCSV(strings.NewReader("id,phrase\n1,Jane Doe\n"), EntCSVOptions{})
+
+	input := strings.Join([]string{
+		"id,title,origin,origin_id,link,duration,tags,models,keywords",
+		`1,First video,Xvideos,x1,https://example.com/1,600,blonde|outdoor,Jane Doe,first video keyword`,
+		`2,"Second, video",alphaporno,a2,https://example.com/2,01:02:03,blonde,,`,
+		`3,Third video,nowhere,,,,,,`,
+		`4,Fourth video,,,,1:xx,,,`,
+	}, "\n")
+
+	opts := EntCSVOptions{
+		Columns: map[string]string{CSVFieldUrl: "link"},
+		DryRun:  true,
+	}
+
+	report, err := entdb.ImportVideosCSV(strings.NewReader(input), opts)
+	if err != nil {
+		t.Fatalf("test import videos csv failed: %v", err)
+	}
+
+	if report.Added != 2 || len(report.Errors) != 2 || len(report.CreatedTags) != 2 || len(report.CreatedModels) != 0 {
+		t.Errorf("test import videos csv dry run failed: got %d/%v/%v/%v", report.Added, report.Errors, report.CreatedTags, report.CreatedModels)
+	}
+
+	if len(entdb.Items) != 0 || len(entdb.DictTags) != 0 {
+		t.Errorf("test import videos csv dry run should not change db")
+	}
+
+	opts.DryRun = false
+	report, _ = entdb.ImportVideosCSV(strings.NewReader(input), opts)
+	if report.Added != 2 {
+		t.Errorf("test import videos csv added failed: got %v, wanted %v", report.Added, 2)
+	}
+
+	video, err := entdb.GetVideoById(2)
+	if err != nil {
+		t.Fatalf("test import videos csv video missing: %v", err)
+	}
+	if video.Title != "Second, video" || video.Origin != OriginAlphaporno || video.Duration != 3723 || video.OriginUrl != "https://example.com/2" {
+		t.Errorf("test import videos csv video failed: got %v", video.ToJSON())
+	}
+
+	video, _ = entdb.GetVideoById(1)
+	if video.GetMetaKeywords() != "blonde,outdoor,Jane Doe" || video.Models[0] != entdb.DictModels[1] {
+		t.Errorf("test import videos csv keywords failed: got %v", video.GetMetaKeywords())
+	}
+
+	Expected := 2
+	Got := len(entdb.Tags["blonde"])
+	if Got != Expected {
+		t.Errorf("test import videos csv tag index failed: got %v, wanted %v", Got, Expected)
+	}
+
+	report, _ = entdb.ImportVideosCSV(strings.NewReader(input), opts)
+	if report.Unchanged != 2 || report.Added != 0 || report.Updated != 0 {
+		t.Errorf("test import videos csv reimport failed: got %d/%d/%d", report.Added, report.Updated, report.Unchanged)
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	cases := map[string]int{"600": 600, "10:00": 600, "01:02:03": 3723}
+	for value, Expected := range cases {
+		Got, err := ParseDuration(value)
+		if err != nil || Got != Expected {
+			t.Errorf("test parse duration %s failed: got %v, wanted %v", value, Got, Expected)
+		}
+	}
+
+	if _, err := ParseDuration("1:2:3:4"); err == nil {
+		t.Errorf("test parse bad duration should fail")
+	}
+}
+
+func TestEntDBImportVideosCSVUpdate(t *testing.T) {
+	entdb := NewEntDB(t.TempDir())
+
+	video := NewEntVideo(entdb)
+	video.Id = 1
+	video.Title = "First video"
+	video.Slug = video.GetSlug()
+	video.Descr = "first description"
+	video.ThumbUrls = []string{"https://example.com/1.jpg"}
+	video.AddKeyword(NewKeyword(0, "first keyword"))
+	entdb.Add(video)
+
+	report, err := entdb.ImportVideosCSV(strings.NewReader("id,title,duration\n1,First video renamed,600\n"), EntCSVOptions{})
+	if err != nil || report.Updated != 1 {
+		t.Fatalf("test import videos csv update failed: got %v, %v", report, err)
+	}
+
+	Got, _ := entdb.GetVideoById(1)
+	if Got.Title != "First video renamed" || Got.Duration != 600 {
+		t.Errorf("test import videos csv update columns failed: got %v", Got.ToJSON())
+	}
+	if Got.Descr != "first description" || len(Got.ThumbUrls) != 1 || len(Got.Keywords) != 1 {
+		t.Errorf("test import videos csv update should keep other fields: got %v", Got.ToJSON())
+	}
+	if Got.Slug != video.Slug {
+		t.Errorf("test import videos csv update slug failed: got %v, wanted %v", Got.Slug, video.Slug)
+	}
+	if _, err := entdb.GetVideoByMD5(video.GetMD5()); err != nil {
+		t.Errorf("test import videos csv update url failed: %v", err)
+	}
+}
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, errors.New("broken pipe")
+}
+
+func TestEntDBImportCSVReaderFailure(t *testing.T) {
+	entdb := NewEntDB(t.TempDir())
+
+	input := io.MultiReader(strings.NewReader("id,phrase\n1,blonde\n"), failingReader{})
+	report, err := entdb.ImportTagsCSV(input, EntCSVOptions{})
+	if err == nil || report.Added != 1 || len(report.Errors) != 0 {
+		t.Errorf("test import tags csv reader failure failed: got %v, %v", report, err)
+	}
+
+	input = io.MultiReader(strings.NewReader("id,title\n"), failingReader{})
+	if _, err := entdb.ImportVideosCSV(input, EntCSVOptions{}); err == nil {
+		t.Errorf("test import videos csv reader failure should fail")
+	}
+}
@@ -1,7 +1,6 @@
 package goentdb
 
 import (
-	"encoding/gob"
 	"fmt"
 	"os"
 )
@@ -14,42 +13,85 @@ import (
 
 */
 func (edb *EntDB) DumpTags() error {
-	return DumpMapToFilepath(edb.GetDictTagsPath(), edb.DictTags, edb.lock)
+	tags := keywordMap(&edb.Index().dictTags)
+
+	_, err := EncodeEntFile(edb.GetDictTagsPath(), EntFileKeywords, edb.Codec, len(tags), tags)
+	return err
 }
 
 func (edb *EntDB) DumpModels() error {
-	return DumpMapToFilepath(edb.GetDictModelsPath(), edb.DictModels, edb.lock)
+	models := keywordMap(&edb.Index().dictModels)
+
+	_, err := EncodeEntFile(edb.GetDictModelsPath(), EntFileKeywords, edb.Codec, len(models), models)
+	return err
 }
 
 func (edb *EntDB) DumpVideos() error {
-	f, err := os.Create(edb.GetDictVideosPath())
+	_, err := edb.encodeVideos(edb.GetDictVideosPath(), edb.Index().Items)
+	return err
+}
+
+func (edb *EntDB) encodeVideos(filepath string, items []*EntVideo) (FileChecksum, error) {
+	return EncodeEntVideos(filepath, edb.Codec, len(items), func(pos int) EntVideoForLoad {
+		return items[pos].ToLoad()
+	})
+}
+
+/*
+Dump every dict as a new snapshot generation committed by the manifest.
+DumpTags/DumpModels/DumpVideos write standalone files, only Dump
+guarantees Load never sees a mix of files from different dumps.
+Writers are only blocked while the snapshot is taken, not while it is written.
+*/
+func (edb *EntDB) Dump() error {
+	return edb.checkpoint(false)
+}
+
+/*
+Take a snapshot and move the WAL aside in one step under the lock, so the
+snapshot holds exactly the records of the moved segment. The segment is
+removed once the generation is committed, until then Load replays it.
+The outcome of a compaction started by a mutation is kept for CompactionErr.
+*/
+func (edb *EntDB) checkpoint(onlyIfNeeded bool) (err error) {
+	edb.dumpLock.Lock()
+	defer edb.dumpLock.Unlock()
+
+	edb.lock.Lock()
+	if onlyIfNeeded && !edb.walNeedsCompaction() {
+		edb.lock.Unlock()
+		return nil
+	}
+	defer func() {
+		if err == nil || onlyIfNeeded {
+			edb.compactErr = err
+		}
+	}()
+	// Published generation is a point-in-time view, it is written without the lock
+	edb.publish()
+	snap := edb.Index()
+	generation, err := edb.nextGeneration()
+	if err == nil {
+		err = edb.rotateWAL()
+	}
+	if err == nil {
+		// Records logged from now on are not in the snapshot
+		edb.generation = generation
+	}
+	edb.lock.Unlock()
 	if err != nil {
 		return err
 	}
 
-	items := make([]EntVideoForLoad, len(edb.Items))
-	for pos, video := range edb.Items {
-		items[pos] = video.ToLoad()
+	fmt.Printf("dumping Tags=%d Models=%d Videos=%d\n", snap.dictTags.len(), snap.dictModels.len(), len(snap.Items))
+	if err := edb.writeGeneration(snap, generation); err != nil {
+		return err
 	}
+	edb.stamp, _ = edb.storageStamp()
 
-	encoder := gob.NewEncoder(f)
-
-	edb.lock.RLock()
-	defer edb.lock.RUnlock()
-
-	if err := encoder.Encode(items); err != nil {
+	if err := os.Remove(edb.GetWALSegmentPath()); err != nil && !os.IsNotExist(err) {
 		return err
 	}
-	f.Close()
-	return nil
-}
 
-func (edb *EntDB) Dump() error {
-	fmt.Printf("dumping Tags=%d\n", len(edb.DictTags))
-	edb.DumpTags()
-	fmt.Printf("dumping Models=%d\n", len(edb.DictModels))
-	edb.DumpModels()
-	fmt.Printf("dumping Videos=%d\n", len(edb.Items))
-	edb.DumpVideos()
 	return nil
 }
@@ -0,0 +1,91 @@
+package goentdb
+
+import (
+	"errors"
+	"fmt"
+)
+
+/*
+Sentinels for errors.Is, lookup, insert and integrity errors of the package wrap one of them
+*/
+var (
+	ErrNotFound  = errors.New("not found")
+	ErrDuplicate = errors.New("already exists")
+	ErrCorrupt   = errors.New("corrupt")
+)
+
+type EntKind uint8
+
+const (
+	EntKindTag EntKind = iota + 1
+	EntKindModel
+	EntKindVideo
+	EntKindKeyword
+)
+
+func (k EntKind) String() string {
+	switch k {
+	case EntKindTag:
+		return "EntKeyword(Tag)"
+	case EntKindModel:
+		return "EntKeyword(Model)"
+	case EntKindVideo:
+		return "EntVideo"
+	case EntKindKeyword:
+		return "EntKeyword"
+	}
+	return fmt.Sprintf("EntKind(%d)", uint8(k))
+}
+
+/*
+Lookup or insert of the entity Kind with Key failed, Err is ErrNotFound or ErrDuplicate
+*/
+type EntError struct {
+	Kind EntKind
+	Key  interface{}
+	Err  error
+}
+
+func (e *EntError) Error() string {
+	return fmt.Sprintf("%s %v: %v", e.Kind, e.Err, e.Key)
+}
+
+func (e *EntError) Unwrap() error {
+	return e.Err
+}
+
+func notFound(kind EntKind, key interface{}) error {
+	return &EntError{Kind: kind, Key: key, Err: ErrNotFound}
+}
+
+func duplicate(kind EntKind, key interface{}) error {
+	return &EntError{Kind: kind, Key: key, Err: ErrDuplicate}
+}
+
+/*
+Snapshot file, WAL or manifest which failed an integrity check.
+errors.Is matches ErrCorrupt as well as the cause in Err.
+*/
+type EntCorruptError struct {
+	Path string // Empty when the caller adds the path itself
+	Err  error
+}
+
+func (e *EntCorruptError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("corrupt: %v", e.Err)
+	}
+	return fmt.Sprintf("%s: corrupt: %v", e.Path, e.Err)
+}
+
+func (e *EntCorruptError) Is(target error) bool {
+	return target == ErrCorrupt
+}
+
+func (e *EntCorruptError) Unwrap() error {
+	return e.Err
+}
+
+func corrupt(path string, err error) error {
+	return &EntCorruptError{Path: path, Err: err}
+}
@@ -0,0 +1,126 @@
+package goentdb
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestEntDBErrorNotFound(t *testing.T) {
+	entdb := GenerateSnapshotEntDB(t.TempDir())
+
+	cases := []struct {
+		Err  error
+		Kind EntKind
+		Key  interface{}
+	}{
+		{Err: errorOf(entdb.GetTagById(42)), Kind: EntKindTag, Key: 42},
+		{Err: errorOf(entdb.GetModelById(42)), Kind: EntKindModel, Key: 42},
+		{Err: errorOf(entdb.GetVideoById(uint(42))), Kind: EntKindVideo, Key: uint(42)},
+		{Err: entdb.Remove(uint(42)), Kind: EntKindVideo, Key: uint(42)},
+	}
+
+	for _, c := range cases {
+		if !errors.Is(c.Err, ErrNotFound) {
+			t.Errorf("test not found failed: got %v, wanted %v", c.Err, ErrNotFound)
+		}
+		var entErr *EntError
+		if !errors.As(c.Err, &entErr) {
+			t.Errorf("test not found typed error failed: got %#v", c.Err)
+			continue
+		}
+		if entErr.Kind != c.Kind || entErr.Key != c.Key {
+			t.Errorf("test not found kind and key failed: got %v %v, wanted %v %v", entErr.Kind, entErr.Key, c.Kind, c.Key)
+		}
+	}
+}
+
+func errorOf[T any](_ T, err error) error {
+	return err
+}
+
+func TestEntDBErrorDuplicate(t *testing.T) {
+	entdb := GenerateSnapshotEntDB(t.TempDir())
+
+	// Tags are upserted, a tag with a stored id replaces it
+	if err := entdb.AddTag(NewTag(1, "tag again")); err != nil {
+		t.Errorf("test upsert tag failed: %v", err)
+	}
+	if entdb.DictTags[1].Phrase != "tag again" {
+		t.Errorf("test upsert tag failed: got %v, wanted %v", entdb.DictTags[1].Phrase, "tag again")
+	}
+	if Got := entdb.Suggest("tag", 10).Tags; len(Got) != 3 || Got[2].Phrase != "tag again" {
+		t.Errorf("test upsert tag suggest failed: got %v", Got)
+	}
+
+	video := NewEntVideo(entdb)
+	video.Id = uint(1)
+	video.Title = "title again"
+	video.Slug = "title-again"
+
+	err := entdb.Add(video)
+	if !errors.Is(err, ErrDuplicate) {
+		t.Errorf("test duplicate video failed: got %v, wanted %v", err, ErrDuplicate)
+	}
+	var entErr *EntError
+	if !errors.As(err, &entErr) || entErr.Kind != EntKindVideo || entErr.Key != uint(1) {
+		t.Errorf("test duplicate video typed error failed: got %#v", err)
+	}
+
+	fresh := NewEntVideo(entdb)
+	fresh.Id = uint(100)
+	fresh.Title = "fresh title"
+	fresh.Slug = "fresh-title"
+
+	// Nothing of the batch is added
+	if err := entdb.AddBatch([]*EntVideo{fresh, video}); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("test duplicate batch failed: got %v, wanted %v", err, ErrDuplicate)
+	}
+	if _, err := entdb.GetVideoById(uint(100)); !errors.Is(err, ErrNotFound) {
+		t.Errorf("test duplicate batch should not add video 100: got %v", err)
+	}
+}
+
+func TestEntDBErrorCorrupt(t *testing.T) {
+	path := t.TempDir()
+	entdb := GenerateSnapshotEntDB(path)
+	entdb.Dump()
+
+	os.WriteFile(entdb.GetGenerationPath(ManifestTags, 1), []byte("garbage"), 0644)
+	manifest, _ := entdb.ReadManifest()
+	if err := entdb.VerifyManifest(manifest); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("test corrupt generation failed: got %v, wanted %v", err, ErrCorrupt)
+	}
+
+	os.WriteFile(entdb.GetManifestPath(), []byte("garbage"), 0644)
+	if _, err := entdb.ReadManifest(); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("test corrupt manifest failed: got %v, wanted %v", err, ErrCorrupt)
+	}
+
+	file := t.TempDir() + "/tags"
+	os.WriteFile(file, []byte("garbage"), 0644)
+	tags := make(map[int]*EntKeyword)
+	if _, err := DecodeEntFile(file, EntFileKeywords, &tags); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("test corrupt snapshot file failed: got %v, wanted %v", err, ErrCorrupt)
+	}
+}
+
+func TestEntWALErrorCorrupt(t *testing.T) {
+	entdb := GenerateWALEntDB(t, t.TempDir())
+	entdb.CloseWAL()
+
+	// Flip a payload byte of the first record, the records after it are intact
+	data, _ := os.ReadFile(entdb.GetWALPath())
+	data[walFrameHeaderSize] ^= 0xff
+	os.WriteFile(entdb.GetWALPath(), data, 0644)
+
+	_, err := ReadEntWAL(entdb.GetWALPath(), func(*EntWALRecord) error { return nil })
+	if !errors.Is(err, ErrCorrupt) {
+		t.Errorf("test corrupt wal failed: got %v, wanted %v", err, ErrCorrupt)
+	}
+
+	var corruptErr *EntCorruptError
+	if !errors.As(err, &corruptErr) || corruptErr.Path != entdb.GetWALPath() {
+		t.Errorf("test corrupt wal path failed: got %#v", err)
+	}
+}
@@ -0,0 +1,205 @@
+package goentdb
+
+import (
+	"fmt"
+)
+
+/*
+Outcome of a bulk import, bad lines are collected instead of stopping the import
+*/
+type EntImportReport struct {
+	DryRun        bool
+	Lines         int
+	Added         int
+	Updated       int
+	Unchanged     int
+	CreatedTags   []*EntKeyword
+	CreatedModels []*EntKeyword
+	Errors        []EntLineError
+}
+
+type EntLineError struct {
+	Line int
+	Err  error
+}
+
+func (e EntLineError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e EntLineError) Unwrap() error {
+	return e.Err
+}
+
+func (r *EntImportReport) addError(line int, err error) {
+	r.Errors = append(r.Errors, EntLineError{Line: line, Err: err})
+}
+
+/*
+Finds tags/models by id or phrase and creates the missing ones through addTag/addModel.
+All methods have to be called with the EntDB lock held. Imports release the
+lock between records, the slug cache is rebuilt once the dict was changed by
+anyone else meanwhile, e.g. a merge or a Reload.
+*/
+type entKeywordResolver struct {
+	edb     *EntDB
+	kind    EntKeywordType
+	dict    map[int]*EntKeyword
+	bySlug  map[string]*EntKeyword
+	created []*EntKeyword // Keywords a dry run would create, they are not in dict
+	edits   uint64        // keywordEdits of edb the cache was built at
+	nextId  int
+	dryRun  bool
+}
+
+func (edb *EntDB) newKeywordResolver(kind EntKeywordType, dryRun bool) *entKeywordResolver {
+	r := &entKeywordResolver{edb: edb, kind: kind, dryRun: dryRun}
+	r.rebuild()
+	return r
+}
+
+func (r *entKeywordResolver) rebuild() {
+	r.dict = r.edb.DictTags
+	if r.kind == EntKeywordModel {
+		r.dict = r.edb.DictModels
+	}
+	r.bySlug = make(map[string]*EntKeyword, len(r.dict))
+	r.nextId = 1
+	r.edits = r.edb.keywordEdits
+
+	for id, keyword := range r.dict {
+		r.bySlug[keyword.GetSlug()] = keyword
+		r.nextId = Max(r.nextId, id+1)
+	}
+	for _, keyword := range r.created {
+		if _, exists := r.bySlug[keyword.GetSlug()]; !exists {
+			r.bySlug[keyword.GetSlug()] = keyword
+			r.nextId = Max(r.nextId, keyword.Id+1)
+		}
+	}
+}
+
+/*
+Keyword with id in the current dict
+*/
+func (r *entKeywordResolver) get(id int) (*EntKeyword, bool) {
+	if r.edits != r.edb.keywordEdits {
+		r.rebuild()
+	}
+	keyword, exists := r.dict[id]
+	return keyword, exists
+}
+
+/*
+Keyword with the slug of phrase, or with the slug it was renamed or merged into
+*/
+func (r *entKeywordResolver) byPhrase(phrase string) (*EntKeyword, bool) {
+	slug := NewEntKeyword(0, phrase, r.kind).GetSlug()
+	if keyword, exists := r.bySlug[slug]; exists {
+		return keyword, true
+	}
+
+	redirects := r.edb.redirects.Tags
+	if r.kind == EntKeywordModel {
+		redirects = r.edb.redirects.Models
+	}
+	if to, exists := redirects[slug]; exists {
+		keyword, exists := r.bySlug[to]
+		return keyword, exists
+	}
+
+	return nil, false
+}
+
+/*
+Return keyword with id or phrase, id 0 means lookup by phrase only.
+The second value is true when the keyword had to be created.
+*/
+func (r *entKeywordResolver) resolve(id int, phrase string) (*EntKeyword, bool, error) {
+	if r.edits != r.edb.keywordEdits {
+		r.rebuild()
+	}
+
+	if id != 0 {
+		if keyword, exists := r.dict[id]; exists {
+			return keyword, false, nil
+		}
+	} else if keyword, exists := r.byPhrase(phrase); exists {
+		return keyword, false, nil
+	}
+
+	if phrase == "" {
+		return nil, false, fmt.Errorf("%w and has no phrase", notFound(r.entKind(), id))
+	}
+
+	if id == 0 {
+		for r.dict[r.nextId] != nil {
+			r.nextId++
+		}
+		id = r.nextId
+		r.nextId++
+	}
+
+	keyword := NewEntKeyword(id, phrase, r.kind)
+	r.bySlug[keyword.GetSlug()] = keyword
+	r.nextId = Max(r.nextId, id+1)
+
+	if r.dryRun {
+		r.created = append(r.created, keyword)
+		return keyword, true, nil
+	}
+
+	var err error
+	if r.kind == EntKeywordModel {
+		err = r.edb.addModel(keyword)
+	} else {
+		err = r.edb.addTag(keyword)
+	}
+	if err != nil {
+		delete(r.bySlug, keyword.GetSlug())
+		return nil, false, err
+	}
+	// The cache holds the own change already
+	r.edits = r.edb.keywordEdits
+
+	return keyword, true, nil
+}
+
+func (r *entKeywordResolver) entKind() EntKind {
+	if r.kind == EntKeywordModel {
+		return EntKindModel
+	}
+	return EntKindTag
+}
+
+/*
+Add video or replace the one with the same Id, returns true for a new video
+*/
+func (edb *EntDB) upsert(video *EntVideo) (bool, error) {
+	added := false
+
+	err := edb.mutate(func() error {
+		old, exists := edb.DictVideos[video.Id]
+		if old == video {
+			return ErrVideoInPlace
+		}
+
+		op := EntWALAddVideo
+		if exists {
+			op = EntWALUpdateVideo
+		}
+		if err := edb.logVideoMutation(op, video); err != nil {
+			return err
+		}
+
+		if exists {
+			edb.remove(old)
+		}
+		edb.add(video)
+		added = !exists
+
+		return nil
+	})
+
+	return added, err
+}
@@ -0,0 +1,209 @@
+package goentdb
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+/*
+JSON Lines representation of EntVideo, one video per line.
+Tags and models are embedded with id and phrase so the file is readable without the dicts.
+*/
+type EntVideoJSON struct {
+	Id         uint             `json:"id"`
+	Title      string           `json:"title"`
+	Origin     string           `json:"origin"`
+	OriginId   string           `json:"origin_id,omitempty"`
+	OriginUrl  string           `json:"origin_url,omitempty"`
+	Duration   int              `json:"duration"`
+	Slug       string           `json:"slug"`
+	Source     string           `json:"source,omitempty"`
+	Descr      string           `json:"descr,omitempty"`
+	ModifiedAt time.Time        `json:"modified_at"`
+	Tags       []EntKeywordJSON `json:"tags"`
+	Models     []EntKeywordJSON `json:"models"`
+	Keywords   []EntKeywordJSON `json:"keywords"`
+	ThumbUrls  []string         `json:"thumb_urls"`
+	VideoUrls  []string         `json:"video_urls"`
+}
+
+type EntKeywordJSON struct {
+	Id     int    `json:"id,omitempty"`
+	Phrase string `json:"phrase"`
+}
+
+func keywordsToJSON(keywords []*EntKeyword) []EntKeywordJSON {
+	res := make([]EntKeywordJSON, len(keywords))
+	for pos, keyword := range keywords {
+		res[pos] = EntKeywordJSON{Id: keyword.Id, Phrase: keyword.Phrase}
+	}
+	return res
+}
+
+func (ev *EntVideo) ToJSON() EntVideoJSON {
+	return EntVideoJSON{
+		Id:         ev.Id,
+		Title:      ev.Title,
+		Origin:     ev.Origin.String(),
+		OriginId:   ev.OriginId,
+		OriginUrl:  ev.OriginUrl,
+		Duration:   ev.Duration,
+		Slug:       ev.Slug,
+		Source:     ev.Source,
+		Descr:      ev.Descr,
+		ModifiedAt: ev.ModifiedAt,
+		Tags:       keywordsToJSON(ev.Tags),
+		Models:     keywordsToJSON(ev.Models),
+		Keywords:   keywordsToJSON(ev.Keywords),
+		ThumbUrls:  ev.ThumbUrls,
+		VideoUrls:  ev.VideoUrls,
+	}
+}
+
+/*
+Write every video as one JSON object per line
+*/
+func (edb *EntDB) ExportJSONL(w io.Writer) error {
+	items := edb.Index().Items
+
+	bw := bufio.NewWriter(w)
+	encoder := json.NewEncoder(bw)
+	encoder.SetEscapeHTML(false)
+
+	for _, video := range items {
+		if err := encoder.Encode(video.ToJSON()); err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+/*
+Add or replace videos from JSON Lines.
+Unknown tags and models are created through AddTag/AddModel, when the id
+is omitted they are matched by phrase, also the old phrase of a renamed or
+merged keyword, and get the next free id.
+Bad lines are reported with their line number and skipped.
+*/
+func (edb *EntDB) ImportJSONL(r io.Reader) (*EntImportReport, error) {
+	report := &EntImportReport{}
+
+	edb.lock.Lock()
+	tags := edb.newKeywordResolver(EntKeywordTag, false)
+	models := edb.newKeywordResolver(EntKeywordModel, false)
+	edb.lock.Unlock()
+
+	br := bufio.NewReader(r)
+	for line := 1; ; line++ {
+		data, err := br.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return report, err
+		}
+
+		if len(strings.TrimSpace(string(data))) > 0 {
+			report.Lines++
+			if lineErr := edb.importJSONLine(data, tags, models, report); lineErr != nil {
+				report.addError(line, lineErr)
+			}
+		}
+
+		if err == io.EOF {
+			return report, nil
+		}
+	}
+}
+
+func (edb *EntDB) importJSONLine(data []byte, tags, models *entKeywordResolver, report *EntImportReport) error {
+	var evj EntVideoJSON
+	if err := json.Unmarshal(data, &evj); err != nil {
+		return err
+	}
+
+	if evj.Id == 0 {
+		return errors.New("video id is required")
+	}
+
+	video := NewEntVideo(edb)
+	video.Id = evj.Id
+	video.Title = evj.Title
+	video.OriginId = evj.OriginId
+	video.OriginUrl = evj.OriginUrl
+	video.Duration = evj.Duration
+	video.Slug = evj.Slug
+	video.Source = evj.Source
+	video.Descr = evj.Descr
+	video.ModifiedAt = evj.ModifiedAt
+	video.ThumbUrls = evj.ThumbUrls
+	video.VideoUrls = evj.VideoUrls
+
+	if evj.Origin != "" {
+		origin, exists := OriginByName(evj.Origin)
+		if !exists {
+			return fmt.Errorf("unknown origin %q", evj.Origin)
+		}
+		video.Origin = origin
+	}
+
+	if video.Slug == "" {
+		video.Slug = video.GetSlug()
+	}
+
+	for _, kw := range evj.Keywords {
+		video.AddKeyword(NewKeyword(kw.Id, kw.Phrase))
+	}
+
+	edb.lock.Lock()
+	err := edb.resolveKeywords(video, evj.Tags, evj.Models, tags, models, report)
+	edb.publish()
+	edb.lock.Unlock()
+	if err != nil {
+		return err
+	}
+
+	added, err := edb.upsert(video)
+	if err != nil {
+		return err
+	}
+	if added {
+		report.Added++
+	} else {
+		report.Updated++
+	}
+
+	return nil
+}
+
+/*
+Attach tags/models to video, the caller holds the lock
+*/
+func (edb *EntDB) resolveKeywords(video *EntVideo, tagList, modelList []EntKeywordJSON, tags, models *entKeywordResolver, report *EntImportReport) error {
+	for _, kw := range tagList {
+		tag, created, err := tags.resolve(kw.Id, kw.Phrase)
+		if err != nil {
+			return err
+		}
+		if created {
+			report.CreatedTags = append(report.CreatedTags, tag)
+		}
+		video.AddTag(tag)
+	}
+
+	for _, kw := range modelList {
+		model, created, err := models.resolve(kw.Id, kw.Phrase)
+		if err != nil {
+			return err
+		}
+		if created {
+			report.CreatedModels = append(report.CreatedModels, model)
+		}
+		video.AddModel(model)
+	}
+
+	return nil
+}
@@ -0,0 +1,171 @@
+package goentdb
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestEntDBExportImportJSONL(t *testing.T) {
+	entdb := GenerateSnapshotEntDB(t.TempDir())
+	entdb.Items[0].Origin = OriginAlphaporno
+	entdb.Items[0].Duration = 600
+
+	var buf bytes.Buffer
+	if err := entdb.ExportJSONL(&buf); err != nil {
+		t.Fatalf("test export jsonl failed: %v", err)
+	}
+
+	Expected := 5
+	Got := strings.Count(buf.String(), "\n")
+	if Got != Expected {
+		t.Errorf("test export jsonl lines failed: got %v, wanted %v", Got, Expected)
+	}
+
+	entdb_new := NewEntDB(t.TempDir())
+	report, err := entdb_new.ImportJSONL(&buf)
+	if err != nil {
+		t.Fatalf("test import jsonl failed: %v", err)
+	}
+	if len(report.Errors) > 0 {
+		t.Errorf("test import jsonl errors: %v", report.Errors)
+	}
+
+	Got = report.Added
+	if Got != Expected {
+		t.Errorf("test import jsonl added failed: got %v, wanted %v", Got, Expected)
+	}
+
+	Expected = 3
+	Got = len(entdb_new.DictTags)
+	if Got != Expected {
+		t.Errorf("test import jsonl tags count failed: got %v, wanted %v", Got, Expected)
+	}
+
+	for _, video := range entdb.Items {
+		imported, err := entdb_new.GetVideoById(video.Id)
+		if err != nil {
+			t.Errorf("test import jsonl video %d missing: %v", video.Id, err)
+			continue
+		}
+		if imported.Title != video.Title || imported.Origin != video.Origin || imported.Duration != video.Duration ||
+			imported.GetMetaKeywords() != video.GetMetaKeywords() {
+			t.Errorf("test import jsonl video failed: got %v, wanted %v", imported.ToJSON(), video.ToJSON())
+		}
+		if imported.Tags[0].Id != video.Tags[0].Id {
+			t.Errorf("test import jsonl tag id failed: got %v, wanted %v", imported.Tags[0].Id, video.Tags[0].Id)
+		}
+	}
+}
+
+func TestEntDBImportJSONLErrors(t *testing.T) {
+	entdb := NewEntDB(t.TempDir())
+	entdb.AddTag(NewTag(5, "Big Tag"))
+
+	input := strings.Join([]string{
+		`{"id": 1, "title": "first video", "origin": "xvideos", "tags": [{"phrase": "big tag"}, {"phrase": "new tag"}], "models": [{"id": 3, "phrase": "Jane Doe"}]}`,
+		`{"id": 2, "title": "broken"`,
+		``,
+		`{"title": "no id"}`,
+		`{"id": 3, "title": "bad origin", "origin": "nowhere"}`,
+		`{"id": 1, "title": "first video fixed", "tags": [{"id": 9}]}`,
+		`{"id": 4, "title": "fourth video", "tags": [{"phrase": "new tag"}]}`,
+		`{"id": 1, "title": "first video fixed", "origin": "Xvideos", "tags": [{"id": 6}]}`,
+	}, "\n")
+
+	report, err := entdb.ImportJSONL(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("test import jsonl failed: %v", err)
+	}
+
+	ExpectedLines := []int{2, 4, 5, 6}
+	if len(report.Errors) != len(ExpectedLines) {
+		t.Fatalf("test import jsonl errors failed: got %v, wanted lines %v", report.Errors, ExpectedLines)
+	}
+	for pos, lineErr := range report.Errors {
+		if lineErr.Line != ExpectedLines[pos] {
+			t.Errorf("test import jsonl error line failed: got %v, wanted %v", lineErr.Line, ExpectedLines[pos])
+		}
+	}
+
+	if report.Added != 2 || report.Updated != 1 {
+		t.Errorf("test import jsonl counts failed: got %d/%d, wanted 2/1", report.Added, report.Updated)
+	}
+
+	if len(report.CreatedTags) != 1 || report.CreatedTags[0].Id != 6 || report.CreatedTags[0].Phrase != "new tag" {
+		t.Errorf("test import jsonl created tags failed: got %v", report.CreatedTags)
+	}
+
+	if len(report.CreatedModels) != 1 || entdb.DictModels[3] == nil {
+		t.Errorf("test import jsonl created models failed: got %v", report.CreatedModels)
+	}
+
+	video, _ := entdb.GetVideoById(1)
+	if video.Title != "first video fixed" || video.Origin != OriginXvideos || video.Tags[0] != entdb.DictTags[6] {
+		t.Errorf("test import jsonl video failed: got %v", video.ToJSON())
+	}
+
+	Expected := 2
+	Got := len(entdb.Tags["new-tag"])
+	if Got != Expected {
+		t.Errorf("test import jsonl tag index failed: got %v, wanted %v", Got, Expected)
+	}
+}
+
+/*
+Reader returning one line per Read, before calls fn with the number of the
+line about to be read
+*/
+type lineReader struct {
+	lines  []string
+	next   int
+	before func(line int)
+}
+
+func (r *lineReader) Read(p []byte) (int, error) {
+	if r.next >= len(r.lines) {
+		return 0, io.EOF
+	}
+	r.next++
+	r.before(r.next)
+	return copy(p, r.lines[r.next-1]+"\n"), nil
+}
+
+func TestEntDBImportJSONLKeywordChanges(t *testing.T) {
+	entdb := NewEntDB(t.TempDir())
+	entdb.AddTag(NewTag(1, "Blonde"))
+	entdb.AddTag(NewTag(2, "Blond"))
+
+	input := &lineReader{lines: []string{
+		`{"id": 1, "title": "first video", "tags": [{"phrase": "blond"}]}`,
+		`{"id": 2, "title": "second video", "tags": [{"phrase": "blond"}]}`,
+		`{"id": 3, "title": "third video", "tags": [{"phrase": "outdoor"}]}`,
+	}}
+	input.before = func(line int) {
+		switch line {
+		case 2:
+			// Merged between two records of the import
+			entdb.MergeTags(1, 2)
+		case 3:
+			// Created by another writer meanwhile
+			entdb.AddTag(NewTag(10, "Outdoor"))
+		}
+	}
+
+	report, err := entdb.ImportJSONL(input)
+	if err != nil || len(report.Errors) > 0 {
+		t.Fatalf("test import jsonl keyword changes failed: %v %v", err, report.Errors)
+	}
+
+	// The merged away phrase resolves to the tag it was merged into
+	if video, _ := entdb.GetVideoById(2); video.Tags[0].Id != 1 {
+		t.Errorf("test import jsonl merged tag failed: got %v, wanted %v", video.Tags[0].Id, 1)
+	}
+	if video, _ := entdb.GetVideoById(3); video.Tags[0].Id != 10 {
+		t.Errorf("test import jsonl tag of another writer failed: got %v, wanted %v", video.Tags[0].Id, 10)
+	}
+	if Got := len(entdb.DictTags); Got != 2 {
+		t.Errorf("test import jsonl tags count failed: got %v, wanted %v", Got, 2)
+	}
+}
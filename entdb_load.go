@@ -1,42 +1,349 @@
 package goentdb
 
 import (
-	"encoding/gob"
+	"errors"
+	"fmt"
 	"os"
+	"strings"
 )
 
+/*
+What Load does with a video record which fails validation
+*/
+type EntLoadPolicy uint8
+
+const (
+	LoadPolicyFail       EntLoadPolicy = iota // Load returns an error
+	LoadPolicySkip                            // Record is dropped and reported
+	LoadPolicyQuarantine                      // Record is dropped, reported and written to the quarantine file
+)
+
+type EntLoadFileError struct {
+	File string
+	Err  error
+}
+
+type EntLoadDangling struct {
+	VideoId uint
+	Id      int
+}
+
+/*
+Outcome of Load
+File level problems (missing or undecodable files) always fail the load,
+record level problems are handled according to EntDB.LoadPolicy.
+*/
+type EntLoadReport struct {
+	Policy          EntLoadPolicy
+	MissingFiles    []string
+	DecodeErrors    []EntLoadFileError
+	DanglingTags    []EntLoadDangling
+	DanglingModels  []EntLoadDangling
+	DuplicateVideos []uint
+	Quarantined     []EntVideoForLoad
+	Loaded          int
+	Rejected        int
+}
+
+func (r *EntLoadReport) HasFileErrors() bool {
+	return len(r.MissingFiles) > 0 || len(r.DecodeErrors) > 0
+}
+
+func (r *EntLoadReport) HasRecordErrors() bool {
+	return r.Rejected > 0
+}
+
+func (r *EntLoadReport) Err() error {
+	if r.HasFileErrors() || (r.Policy == LoadPolicyFail && r.HasRecordErrors()) {
+		return &EntLoadError{Report: r}
+	}
+	return nil
+}
+
+type EntLoadError struct {
+	Report *EntLoadReport
+}
+
+func (e *EntLoadError) Error() string {
+	r := e.Report
+	parts := make([]string, 0)
+
+	for _, file := range r.MissingFiles {
+		parts = append(parts, fmt.Sprintf("missing %s", file))
+	}
+	for _, decode := range r.DecodeErrors {
+		parts = append(parts, decode.Err.Error())
+	}
+	if len(r.DanglingTags) > 0 {
+		parts = append(parts, fmt.Sprintf("%d dangling tag ids", len(r.DanglingTags)))
+	}
+	if len(r.DanglingModels) > 0 {
+		parts = append(parts, fmt.Sprintf("%d dangling model ids", len(r.DanglingModels)))
+	}
+	if len(r.DuplicateVideos) > 0 {
+		parts = append(parts, fmt.Sprintf("%d duplicate video ids", len(r.DuplicateVideos)))
+	}
+
+	return fmt.Sprintf("load failed: %s", strings.Join(parts, "; "))
+}
+
+/*
+errors.Is and errors.As look through every decode error. Is and As are
+implemented instead of Unwrap() []error, which errors only follows from Go 1.20.
+*/
+func (e *EntLoadError) Is(target error) bool {
+	for _, decode := range e.Report.DecodeErrors {
+		if errors.Is(decode.Err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *EntLoadError) As(target interface{}) bool {
+	for _, decode := range e.Report.DecodeErrors {
+		if errors.As(decode.Err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *EntLoadReport) addFileError(file string, err error) {
+	if os.IsNotExist(err) {
+		r.MissingFiles = append(r.MissingFiles, file)
+		return
+	}
+	r.DecodeErrors = append(r.DecodeErrors, EntLoadFileError{File: file, Err: err})
+}
+
+func (edb *EntDB) GetQuarantinePath() string {
+	return fmt.Sprintf("%s/quarantine", edb.StoragePath)
+}
+
 func (edb *EntDB) LoadTags() error {
-	return LoadMapFromFilepath(edb.GetDictTagsPath(), &edb.DictTags, edb.lock)
+	defer edb.indexKeywords()
+	return LoadMapFromFilepath(edb.GetDictTagsPath(), &edb.DictTags, &edb.lock)
 }
 
 func (edb *EntDB) LoadModels() error {
-	return LoadMapFromFilepath(edb.GetDictModelsPath(), &edb.DictModels, edb.lock)
+	defer edb.indexKeywords()
+	return LoadMapFromFilepath(edb.GetDictModelsPath(), &edb.DictModels, &edb.lock)
+}
+
+/*
+LoadMapFromFilepath fills the writer dicts only, publish what it merged
+*/
+func (edb *EntDB) indexKeywords() {
+	edb.lock.Lock()
+	defer edb.lock.Unlock()
+
+	for id := range edb.DictTags {
+		edb.indexTag(id)
+	}
+	for id := range edb.DictModels {
+		edb.indexModel(id)
+	}
+	edb.publish()
 }
 
 func (edb *EntDB) LoadVideos() error {
-	f, err := os.Open(edb.GetDictVideosPath())
-	if err != nil {
+	report := &EntLoadReport{Policy: edb.LoadPolicy}
+	if err := edb.loadVideosFromFilepath(edb.GetDictVideosPath(), report); err != nil {
 		return err
 	}
+	return report.Err()
+}
 
-	decoder := gob.NewDecoder(f)
+/*
+Videos are indexed as they are decoded, the whole file is never held in memory
+*/
+func (edb *EntDB) loadVideosFromFilepath(filepath string, report *EntLoadReport) error {
+	edb.lock.Lock()
+	defer edb.lock.Unlock()
+	defer edb.publish()
 
-	//edb.lock.RLock()
-	//defer edb.lock.RUnlock()
+	_, err := DecodeEntVideos(filepath, func(evfl *EntVideoForLoad) error {
+		edb.addVideoFromLoadChecked(evfl, report)
+		return nil
+	})
+
+	return err
+}
 
-	items := make([]EntVideoForLoad, 0)
-	decoder.Decode(&items)
+/*
+Validate references of evfl and add it, rejected records go to the report
+*/
+func (edb *EntDB) addVideoFromLoadChecked(evfl *EntVideoForLoad, report *EntLoadReport) {
+	valid := edb.checkReferences(evfl, report)
 
-	for _, v := range items {
-		edb.AddVideoFromLoad(&v)
+	if _, exists := edb.DictVideos[evfl.Id]; exists {
+		report.DuplicateVideos = append(report.DuplicateVideos, evfl.Id)
+		valid = false
 	}
 
-	f.Close()
-	return nil
+	if !valid {
+		report.reject(evfl)
+		return
+	}
+
+	ev, _ := edb.videoFromLoad(evfl)
+	edb.add(ev)
+	report.Loaded++
+}
+
+/*
+Tags and models of evfl are in the dicts, dangling ids go to the report
+*/
+func (edb *EntDB) checkReferences(evfl *EntVideoForLoad, report *EntLoadReport) bool {
+	valid := true
+
+	for _, tag_id := range evfl.Tags {
+		if _, exists := edb.DictTags[tag_id]; !exists {
+			report.DanglingTags = append(report.DanglingTags, EntLoadDangling{VideoId: evfl.Id, Id: tag_id})
+			valid = false
+		}
+	}
+	for _, model_id := range evfl.Models {
+		if _, exists := edb.DictModels[model_id]; !exists {
+			report.DanglingModels = append(report.DanglingModels, EntLoadDangling{VideoId: evfl.Id, Id: model_id})
+			valid = false
+		}
+	}
+
+	return valid
+}
+
+func (r *EntLoadReport) reject(evfl *EntVideoForLoad) {
+	r.Rejected++
+	if r.Policy == LoadPolicyQuarantine {
+		r.Quarantined = append(r.Quarantined, *evfl)
+	}
+}
+
+/*
+Load the generation committed by the manifest and replay the WAL on top.
+Storages dumped before manifests existed fall back to the standalone files.
+The state is loaded into a fresh EntDB and replaces the state of edb only
+when the load succeeds, a failed load leaves edb untouched.
+*/
+func (edb *EntDB) Load() error {
+	_, err := edb.LoadWithReport()
+	return err
+}
+
+func (edb *EntDB) LoadWithReport() (*EntLoadReport, error) {
+	edb.dumpLock.Lock()
+	defer edb.dumpLock.Unlock()
+
+	fresh := edb.newFresh()
+	report := &EntLoadReport{Policy: fresh.LoadPolicy}
+
+	fresh.loadSnapshot(report)
+
+	if err := fresh.replayWAL(report); err != nil {
+		report.addFileError(fresh.GetWALPath(), err)
+	}
+
+	fresh.writeQuarantine(report)
+
+	if err := report.Err(); err != nil {
+		return report, err
+	}
+
+	for _, video := range fresh.Items {
+		video.Owner = edb
+	}
+
+	edb.lock.Lock()
+	defer edb.lock.Unlock()
+
+	edb.swapState(fresh)
+	edb.generation = fresh.generation
+
+	return report, nil
+}
+
+/*
+Empty EntDB on the storage of edb with its settings, state is loaded into
+it before it is swapped in
+*/
+func (edb *EntDB) newFresh() *EntDB {
+	fresh := NewEntDB(edb.StoragePath)
+	fresh.LoadPolicy = edb.LoadPolicy
+	fresh.Codec = edb.Codec
+	fresh.ThumbBaseUrl = edb.ThumbBaseUrl
+	fresh.Language = edb.Language
+
+	edb.lock.RLock()
+	fresh.synonyms = edb.synonyms
+	edb.lock.RUnlock()
+
+	return fresh
 }
 
-func (edb *EntDB) Load() {
-	edb.LoadTags()
-	edb.LoadModels()
-	edb.LoadVideos()
+/*
+Load the snapshot without the WAL, the WAL records older than its
+generation are skipped by ReplayWAL afterwards
+*/
+func (edb *EntDB) loadSnapshot(report *EntLoadReport) {
+	// Taken first, a snapshot written while loading shows up as a change
+	stamp, _ := edb.storageStamp()
+	defer func() {
+		edb.dumpLock.Lock()
+		edb.stamp = stamp
+		edb.dumpLock.Unlock()
+	}()
+
+	manifest, err := edb.ReadManifest()
+	switch {
+	case err == nil:
+		edb.loadGeneration(manifest, report)
+		edb.lock.Lock()
+		edb.generation = manifest.Generation
+		edb.lock.Unlock()
+	case os.IsNotExist(err):
+		edb.loadStandalone(report)
+	default:
+		report.addFileError(edb.GetManifestPath(), err)
+	}
+}
+
+func (edb *EntDB) writeQuarantine(report *EntLoadReport) {
+	if len(report.Quarantined) > 0 {
+		_, err := EncodeEntVideos(edb.GetQuarantinePath(), edb.Codec, len(report.Quarantined), func(pos int) EntVideoForLoad {
+			return report.Quarantined[pos]
+		})
+		if err != nil {
+			report.addFileError(edb.GetQuarantinePath(), err)
+		}
+	}
+}
+
+/*
+An empty storage is a fresh DB, only a partial set of files is reported missing
+*/
+func (edb *EntDB) loadStandalone(report *EntLoadReport) {
+	paths := []string{edb.GetDictTagsPath(), edb.GetDictModelsPath(), edb.GetDictVideosPath()}
+
+	missing := make([]string, 0)
+	for _, path := range paths {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			missing = append(missing, path)
+		}
+	}
+	if len(missing) == len(paths) {
+		return
+	}
+	report.MissingFiles = append(report.MissingFiles, missing...)
+
+	if err := edb.LoadTags(); err != nil && !os.IsNotExist(err) {
+		report.addFileError(edb.GetDictTagsPath(), err)
+	}
+	if err := edb.LoadModels(); err != nil && !os.IsNotExist(err) {
+		report.addFileError(edb.GetDictModelsPath(), err)
+	}
+	if err := edb.loadVideosFromFilepath(edb.GetDictVideosPath(), report); err != nil && !os.IsNotExist(err) {
+		report.addFileError(edb.GetDictVideosPath(), err)
+	}
 }
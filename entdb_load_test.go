@@ -0,0 +1,192 @@
+package goentdb
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func GenerateLoadEntDB(t *testing.T, policy EntLoadPolicy) *EntDB {
+	path := t.TempDir()
+	entdb := NewEntDB(path)
+	entdb.LoadPolicy = policy
+
+	tags := map[int]*EntKeyword{1: NewTag(1, "tag 1")}
+	models := map[int]*EntKeyword{1: NewModel(1, "model 1")}
+	videos := []EntVideoForLoad{
+		{Id: 1, Title: "title number 1", Slug: "title-number-1", Tags: []int{1}, Models: []int{1}},
+		{Id: 2, Title: "title number 2", Slug: "title-number-2", Tags: []int{1, 7}},
+		{Id: 3, Title: "title number 3", Slug: "title-number-3", Models: []int{9}},
+		{Id: 1, Title: "title number 1 again", Slug: "title-number-1-again"},
+		{Id: 4, Title: "title number 4", Slug: "title-number-4", Tags: []int{1}},
+	}
+
+	EncodeEntFile(entdb.GetDictTagsPath(), EntFileKeywords, CodecNone, len(tags), tags)
+	EncodeEntFile(entdb.GetDictModelsPath(), EntFileKeywords, CodecNone, len(models), models)
+	EncodeEntVideos(entdb.GetDictVideosPath(), CodecNone, len(videos), func(pos int) EntVideoForLoad {
+		return videos[pos]
+	})
+
+	return entdb
+}
+
+func TestEntDBLoadEmpty(t *testing.T) {
+	entdb := NewEntDB(t.TempDir())
+
+	if err := entdb.Load(); err != nil {
+		t.Errorf("test load of empty storage failed: %v", err)
+	}
+}
+
+func TestEntDBLoadMissingFile(t *testing.T) {
+	entdb := GenerateLoadEntDB(t, LoadPolicySkip)
+	os.Remove(entdb.GetDictModelsPath())
+
+	report, err := entdb.LoadWithReport()
+	if err == nil {
+		t.Errorf("test load with missing file should fail")
+	}
+
+	var loadErr *EntLoadError
+	if !errors.As(err, &loadErr) {
+		t.Errorf("test load error type failed: got %T", err)
+	}
+
+	Expected := 1
+	Got := len(report.MissingFiles)
+	if Got != Expected {
+		t.Errorf("test load missing files failed: got %v, wanted %v", Got, Expected)
+	}
+}
+
+func TestEntDBLoadDecodeError(t *testing.T) {
+	entdb := GenerateLoadEntDB(t, LoadPolicySkip)
+	os.WriteFile(entdb.GetDictVideosPath(), []byte("garbage"), 0644)
+
+	report, err := entdb.LoadWithReport()
+	if err == nil {
+		t.Errorf("test load with undecodable file should fail")
+	}
+
+	Expected := 1
+	Got := len(report.DecodeErrors)
+	if Got != Expected {
+		t.Errorf("test load decode errors failed: got %v, wanted %v", Got, Expected)
+	}
+	// The decode errors are reachable through errors.Is and errors.As
+	if !errors.Is(err, ErrCorrupt) {
+		t.Errorf("test load decode error is failed: got %v, wanted %v", err, ErrCorrupt)
+	}
+	var corruptErr *EntCorruptError
+	if !errors.As(err, &corruptErr) {
+		t.Errorf("test load decode error as failed: got %#v", corruptErr)
+	}
+}
+
+func TestEntDBLoadPolicyFail(t *testing.T) {
+	entdb := GenerateLoadEntDB(t, LoadPolicyFail)
+
+	report, err := entdb.LoadWithReport()
+	if err == nil {
+		t.Errorf("test load with dangling ids should fail")
+	}
+
+	if len(report.DanglingTags) != 1 || report.DanglingTags[0] != (EntLoadDangling{VideoId: 2, Id: 7}) {
+		t.Errorf("test load dangling tags failed: got %v", report.DanglingTags)
+	}
+	if len(report.DanglingModels) != 1 || report.DanglingModels[0] != (EntLoadDangling{VideoId: 3, Id: 9}) {
+		t.Errorf("test load dangling models failed: got %v", report.DanglingModels)
+	}
+	if len(report.DuplicateVideos) != 1 || report.DuplicateVideos[0] != 1 {
+		t.Errorf("test load duplicate videos failed: got %v", report.DuplicateVideos)
+	}
+	// Nothing of a failed load is left in the receiver
+	if len(entdb.Items) != 0 || len(entdb.DictTags) != 0 || entdb.Index().Len() != 0 {
+		t.Errorf("test load fail should leave the db untouched: got %d/%d", len(entdb.Items), len(entdb.DictTags))
+	}
+}
+
+func TestEntDBLoadWALPolicy(t *testing.T) {
+	entdb := GenerateLoadEntDB(t, LoadPolicySkip)
+
+	wal, err := OpenEntWAL(entdb.GetWALPath())
+	if err != nil {
+		t.Fatalf("test open wal failed: %v", err)
+	}
+	wal.Append(
+		&EntWALRecord{Op: EntWALAddVideo, Video: &EntVideoForLoad{Id: 5, Title: "title number 5", Slug: "title-number-5", Tags: []int{8}}},
+		&EntWALRecord{Op: EntWALAddVideo, Video: &EntVideoForLoad{Id: 6, Title: "title number 6", Slug: "title-number-6", Tags: []int{1}}},
+	)
+	wal.Close()
+
+	report, err := entdb.LoadWithReport()
+	if err != nil {
+		t.Fatalf("test load wal with skip policy failed: %v", err)
+	}
+	if _, err := entdb.GetVideoById(6); err != nil {
+		t.Errorf("test load wal valid record failed: %v", err)
+	}
+	if report.Rejected != 4 || report.DanglingTags[len(report.DanglingTags)-1] != (EntLoadDangling{VideoId: 5, Id: 8}) {
+		t.Errorf("test load wal dangling record failed: got %v/%v", report.Rejected, report.DanglingTags)
+	}
+
+	entdb.LoadPolicy = LoadPolicyFail
+	if err := entdb.Load(); err == nil {
+		t.Errorf("test load wal with fail policy should fail")
+	}
+	if Got := entdb.Index().Len(); Got != 3 {
+		t.Errorf("test failed load should keep the loaded state: got %v, wanted %v", Got, 3)
+	}
+}
+
+func TestEntDBLoadPolicySkip(t *testing.T) {
+	entdb := GenerateLoadEntDB(t, LoadPolicySkip)
+
+	report, err := entdb.LoadWithReport()
+	if err != nil {
+		t.Errorf("test load with skip policy failed: %v", err)
+	}
+
+	Expected := 2
+	Got := len(entdb.Items)
+	if Got != Expected {
+		t.Errorf("test load skip items count failed: got %v, wanted %v", Got, Expected)
+	}
+
+	Expected = 3
+	Got = report.Rejected
+	if Got != Expected {
+		t.Errorf("test load skip rejected count failed: got %v, wanted %v", Got, Expected)
+	}
+
+	if _, err := os.Stat(entdb.GetQuarantinePath()); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("test load skip should not write quarantine: %v", err)
+	}
+}
+
+func TestEntDBLoadPolicyQuarantine(t *testing.T) {
+	entdb := GenerateLoadEntDB(t, LoadPolicyQuarantine)
+
+	if err := entdb.Load(); err != nil {
+		t.Errorf("test load with quarantine policy failed: %v", err)
+	}
+
+	quarantined := make([]EntVideoForLoad, 0)
+	_, err := DecodeEntVideos(entdb.GetQuarantinePath(), func(evfl *EntVideoForLoad) error {
+		quarantined = append(quarantined, *evfl)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("test load quarantine file failed: %v", err)
+	}
+
+	Expected := []uint{2, 3, 1}
+	if len(quarantined) != len(Expected) {
+		t.Fatalf("test load quarantine count failed: got %v, wanted %v", len(quarantined), len(Expected))
+	}
+	for i, evfl := range quarantined {
+		if evfl.Id != Expected[i] {
+			t.Errorf("test load quarantine item failed: got %v, wanted %v", evfl.Id, Expected[i])
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package goentdb
+
+import (
+	"encoding/gob"
+	"fmt"
+	"os"
+	"time"
+)
+
+/*
+Manifest ties tags/models/videos/redirects files of one snapshot generation together.
+Dump writes the generation files first and commits them by replacing the
+manifest, so Load either sees the complete old or the complete new set.
+*/
+type EntManifest struct {
+	Generation uint64
+	CreatedAt  time.Time
+	Files      map[string]EntManifestFile
+}
+
+type EntManifestFile struct {
+	Name     string // Relative to StoragePath
+	Checksum FileChecksum
+}
+
+const (
+	ManifestTags      = "tags"
+	ManifestModels    = "models"
+	ManifestVideos    = "videos"
+	ManifestRedirects = "redirects"
+)
+
+func (edb *EntDB) GetManifestPath() string {
+	return fmt.Sprintf("%s/manifest", edb.StoragePath)
+}
+
+func (edb *EntDB) GetGenerationPath(name string, generation uint64) string {
+	return fmt.Sprintf("%s/%s", edb.StoragePath, GenerationFileName(name, generation))
+}
+
+func GenerationFileName(name string, generation uint64) string {
+	return fmt.Sprintf("%s.%06d", name, generation)
+}
+
+/*
+Read manifest, os.ErrNotExist is returned for a storage without one
+*/
+func (edb *EntDB) ReadManifest() (*EntManifest, error) {
+	f, err := os.Open(edb.GetManifestPath())
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	manifest := &EntManifest{}
+	if err := gob.NewDecoder(f).Decode(manifest); err != nil {
+		return nil, corrupt(edb.GetManifestPath(), err)
+	}
+
+	return manifest, nil
+}
+
+/*
+Check every file of the manifest against its recorded size and checksum
+*/
+func (edb *EntDB) VerifyManifest(manifest *EntManifest) error {
+	for _, file := range manifest.Files {
+		if err := edb.verifyManifestFile(file); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (edb *EntDB) verifyManifestFile(file EntManifestFile) error {
+	path := fmt.Sprintf("%s/%s", edb.StoragePath, file.Name)
+
+	checksum, err := ChecksumFile(path)
+	if err != nil {
+		return err
+	}
+
+	if checksum != file.Checksum {
+		return corrupt(path, fmt.Errorf("checksum mismatch: got %s/%d, wanted %s/%d",
+			checksum.SHA256, checksum.Size, file.Checksum.SHA256, file.Checksum.Size))
+	}
+
+	return nil
+}
+
+/*
+Generation the next snapshot is written as, the caller holds dumpLock
+*/
+func (edb *EntDB) nextGeneration() (uint64, error) {
+	manifest, err := edb.ReadManifest()
+	if os.IsNotExist(err) {
+		return 1, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return manifest.Generation + 1, nil
+}
+
+/*
+Write snap as snapshot generation, the caller holds dumpLock.
+WAL records are stamped with generation already, so it fails when another
+process committed that generation in the meantime.
+*/
+func (edb *EntDB) writeGeneration(snap *EntIndex, generation uint64) error {
+	var previous *EntManifest
+	if manifest, err := edb.ReadManifest(); err == nil {
+		previous = manifest
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	if previous != nil && previous.Generation >= generation {
+		return fmt.Errorf("%s: generation %d was committed while generation %d was written", edb.StoragePath, previous.Generation, generation)
+	}
+
+	manifest := &EntManifest{
+		Generation: generation,
+		CreatedAt:  time.Now(),
+		Files:      make(map[string]EntManifestFile),
+	}
+
+	commit := func(name string, checksum FileChecksum, err error) error {
+		if err != nil {
+			return err
+		}
+		manifest.Files[name] = EntManifestFile{
+			Name:     GenerationFileName(name, manifest.Generation),
+			Checksum: checksum,
+		}
+		return nil
+	}
+
+	path := func(name string) string {
+		return edb.GetGenerationPath(name, manifest.Generation)
+	}
+
+	tags := keywordMap(&snap.dictTags)
+	checksum, err := EncodeEntFile(path(ManifestTags), EntFileKeywords, edb.Codec, len(tags), tags)
+	if err := commit(ManifestTags, checksum, err); err != nil {
+		return err
+	}
+	models := keywordMap(&snap.dictModels)
+	checksum, err = EncodeEntFile(path(ManifestModels), EntFileKeywords, edb.Codec, len(models), models)
+	if err := commit(ManifestModels, checksum, err); err != nil {
+		return err
+	}
+	checksum, err = edb.encodeVideos(path(ManifestVideos), snap.Items)
+	if err := commit(ManifestVideos, checksum, err); err != nil {
+		return err
+	}
+	checksum, err = EncodeEntFile(path(ManifestRedirects), EntFileRedirects, edb.Codec, snap.redirects.len(), snap.redirects)
+	if err := commit(ManifestRedirects, checksum, err); err != nil {
+		return err
+	}
+
+	// Replacing the manifest is the commit point of the generation
+	if err := EncodeToFilepath(edb.GetManifestPath(), manifest); err != nil {
+		return err
+	}
+
+	if previous != nil {
+		for _, file := range previous.Files {
+			os.Remove(fmt.Sprintf("%s/%s", edb.StoragePath, file.Name))
+		}
+	}
+
+	return nil
+}
+
+/*
+Load generation recorded in the manifest, a file is only decoded once its checksum matches
+*/
+func (edb *EntDB) loadGeneration(manifest *EntManifest, report *EntLoadReport) {
+	valid := make(map[string]string)
+
+	for _, name := range []string{ManifestTags, ManifestModels, ManifestVideos} {
+		file, exists := manifest.Files[name]
+		if !exists {
+			report.addFileError(fmt.Sprintf("%s (generation %d)", name, manifest.Generation), os.ErrNotExist)
+			continue
+		}
+
+		path := fmt.Sprintf("%s/%s", edb.StoragePath, file.Name)
+		if err := edb.verifyManifestFile(file); err != nil {
+			report.addFileError(path, err)
+			continue
+		}
+		valid[name] = path
+	}
+
+	// Generations written before redirects existed have none, live slugs drop theirs in indexKeywords
+	if file, exists := manifest.Files[ManifestRedirects]; exists {
+		path := fmt.Sprintf("%s/%s", edb.StoragePath, file.Name)
+		if err := edb.verifyManifestFile(file); err != nil {
+			report.addFileError(path, err)
+		} else if err := edb.loadRedirects(path); err != nil {
+			report.addFileError(path, err)
+		}
+	}
+
+	if path, exists := valid[ManifestTags]; exists {
+		if err := LoadMapFromFilepath(path, &edb.DictTags, &edb.lock); err != nil {
+			report.addFileError(path, err)
+		}
+	}
+	if path, exists := valid[ManifestModels]; exists {
+		if err := LoadMapFromFilepath(path, &edb.DictModels, &edb.lock); err != nil {
+			report.addFileError(path, err)
+		}
+	}
+	edb.indexKeywords()
+	if path, exists := valid[ManifestVideos]; exists {
+		if err := edb.loadVideosFromFilepath(path, report); err != nil {
+			report.addFileError(path, err)
+		}
+	}
+}
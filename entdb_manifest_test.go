@@ -0,0 +1,148 @@
+package goentdb
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func GenerateSnapshotEntDB(path string) *EntDB {
+	entdb := NewEntDB(path)
+
+	for i := 1; i <= 3; i++ {
+		entdb.AddTag(NewTag(i, fmt.Sprintf("tag %d", i)))
+		entdb.AddModel(NewModel(i, fmt.Sprintf("model %d", i)))
+	}
+
+	for i := 1; i <= 5; i++ {
+		video := NewEntVideo(entdb)
+		video.Id = uint(i)
+		video.Title = fmt.Sprintf("title number %d", i)
+		video.Slug = fmt.Sprintf("title-number-%d", i)
+		video.AddTag(entdb.DictTags[1+i%3])
+		video.AddModel(entdb.DictModels[1+i%3])
+		entdb.Add(video)
+	}
+
+	return entdb
+}
+
+func TestEntDBDumpGeneration(t *testing.T) {
+	path := t.TempDir()
+	entdb := GenerateSnapshotEntDB(path)
+
+	if err := entdb.Dump(); err != nil {
+		t.Fatalf("test dump failed: %v", err)
+	}
+	if err := entdb.Dump(); err != nil {
+		t.Fatalf("test dump failed: %v", err)
+	}
+
+	manifest, err := entdb.ReadManifest()
+	if err != nil {
+		t.Fatalf("test read manifest failed: %v", err)
+	}
+
+	Expected := uint64(2)
+	if manifest.Generation != Expected {
+		t.Errorf("test manifest generation failed: got %v, wanted %v", manifest.Generation, Expected)
+	}
+
+	if _, err := os.Stat(entdb.GetGenerationPath(ManifestVideos, 1)); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("test previous generation should be removed: %v", err)
+	}
+
+	leftovers, _ := filepath.Glob(filepath.Join(path, "*.tmp-*"))
+	if len(leftovers) > 0 {
+		t.Errorf("test dump temp files left: %v", leftovers)
+	}
+
+	entdb_new := NewEntDB(path)
+	entdb_new.Load()
+
+	if len(entdb_new.Items) != 5 || len(entdb_new.DictTags) != 3 || len(entdb_new.DictModels) != 3 {
+		t.Errorf("test load generation failed: got %d/%d/%d, wanted 5/3/3",
+			len(entdb_new.Items), len(entdb_new.DictTags), len(entdb_new.DictModels))
+	}
+}
+
+func TestEntDBDumpGenerationUncommitted(t *testing.T) {
+	path := t.TempDir()
+	entdb := GenerateSnapshotEntDB(path)
+	entdb.Dump()
+
+	// Crash before the manifest of generation 2 was written
+	os.WriteFile(entdb.GetGenerationPath(ManifestVideos, 2), []byte("garbage"), 0644)
+
+	entdb_new := NewEntDB(path)
+	entdb_new.Load()
+
+	Expected := 5
+	Got := len(entdb_new.Items)
+	if Got != Expected {
+		t.Errorf("test load committed generation failed: got %v, wanted %v", Got, Expected)
+	}
+}
+
+func TestEntDBDumpGenerationCorrupt(t *testing.T) {
+	path := t.TempDir()
+	entdb := GenerateSnapshotEntDB(path)
+	entdb.Dump()
+
+	os.WriteFile(entdb.GetGenerationPath(ManifestTags, 1), []byte("garbage"), 0644)
+
+	manifest, _ := entdb.ReadManifest()
+	if err := entdb.VerifyManifest(manifest); err == nil {
+		t.Errorf("test verify corrupt generation should fail")
+	}
+
+	entdb_new := NewEntDB(path)
+	if err := entdb_new.Load(); err == nil {
+		t.Errorf("test load corrupt generation should fail")
+	}
+
+	Expected := 0
+	Got := len(entdb_new.Items)
+	if Got != Expected {
+		t.Errorf("test load corrupt generation failed: got %v, wanted %v", Got, Expected)
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file")
+	os.WriteFile(path, []byte("original"), 0644)
+
+	_, err := WriteFileAtomic(path, func(w io.Writer) error {
+		w.Write([]byte("partial"))
+		return errors.New("disk full")
+	})
+	if err == nil {
+		t.Errorf("test atomic write error should be returned")
+	}
+
+	data, _ := os.ReadFile(path)
+	if string(data) != "original" {
+		t.Errorf("test atomic write failed: got %s, wanted %s", data, "original")
+	}
+
+	checksum, err := WriteFileAtomic(path, func(w io.Writer) error {
+		_, err := w.Write([]byte("replaced"))
+		return err
+	})
+	if err != nil {
+		t.Errorf("test atomic write failed: %v", err)
+	}
+
+	Got, _ := ChecksumFile(path)
+	if Got != checksum {
+		t.Errorf("test atomic write checksum failed: got %v, wanted %v", Got, checksum)
+	}
+
+	leftovers, _ := filepath.Glob(path + ".tmp-*")
+	if len(leftovers) > 0 {
+		t.Errorf("test atomic write temp files left: %v", leftovers)
+	}
+}
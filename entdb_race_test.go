@@ -0,0 +1,208 @@
+package goentdb
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+)
+
+const (
+	raceWriters         = 4
+	raceVideosPerWriter = 100
+)
+
+/*
+Writers keep adding while dumps and queries run, run with -race
+*/
+func TestEntDBConcurrentAddDump(t *testing.T) {
+	path := t.TempDir()
+
+	entdb := NewEntDB(path)
+	entdb.WALThreshold = 16 * 1024
+	if err := entdb.OpenWAL(); err != nil {
+		t.Fatalf("test open wal failed: %v", err)
+	}
+	for i := 1; i <= 3; i++ {
+		entdb.AddTag(NewTag(i, fmt.Sprintf("tag %d", i)))
+	}
+
+	var writers sync.WaitGroup
+	var readers sync.WaitGroup
+	done := make(chan struct{})
+
+	for w := 0; w < raceWriters; w++ {
+		writers.Add(1)
+		go func(w int) {
+			defer writers.Done()
+			for i := 1; i <= raceVideosPerWriter; i++ {
+				id := w*raceVideosPerWriter + i
+				tag, _ := entdb.GetTagById(1 + id%3)
+
+				video := NewEntVideo(entdb)
+				video.Id = uint(id)
+				video.Title = fmt.Sprintf("title number %d", id)
+				video.Slug = fmt.Sprintf("title-number-%d", id)
+				video.AddTag(tag)
+				if err := entdb.Add(video); err != nil {
+					t.Errorf("test concurrent add failed: %v", err)
+				}
+			}
+		}(w)
+	}
+
+	readers.Add(2)
+	go func() {
+		defer readers.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			if err := entdb.Dump(); err != nil {
+				t.Errorf("test concurrent dump failed: %v", err)
+			}
+		}
+	}()
+	go func() {
+		defer readers.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			entdb.GetVideoById(uint(1))
+			entdb.GetVideoByMD5(MD5("title-number-1"))
+			entdb.DumpTags()
+			entdb.ExportJSONL(io.Discard)
+		}
+	}()
+
+	writers.Wait()
+	close(done)
+	readers.Wait()
+
+	entdb.CloseWAL()
+
+	entdb_new := NewEntDB(path)
+	if err := entdb_new.Load(); err != nil {
+		t.Fatalf("test load after concurrent dump failed: %v", err)
+	}
+
+	Expected := raceWriters * raceVideosPerWriter
+	Got := len(entdb_new.Items)
+	if Got != Expected {
+		t.Errorf("test concurrent add dump items count failed: got %v, wanted %v", Got, Expected)
+	}
+}
+
+/*
+A checkpoint which moved the WAL aside but never committed its generation
+*/
+func TestEntWALSegmentReplay(t *testing.T) {
+	path := t.TempDir()
+	entdb := GenerateWALEntDB(t, path)
+
+	if err := entdb.wal.Rotate(entdb.GetWALSegmentPath()); err != nil {
+		t.Fatalf("test wal rotate failed: %v", err)
+	}
+
+	video := NewEntVideo(entdb)
+	video.Id = uint(6)
+	video.Title = "title number 6"
+	video.Slug = "title-number-6"
+	entdb.Add(video)
+
+	// Second failed checkpoint appends to the segment left by the first one
+	if err := entdb.wal.Rotate(entdb.GetWALSegmentPath()); err != nil {
+		t.Fatalf("test wal rotate failed: %v", err)
+	}
+	entdb.Remove(uint(1))
+	entdb.CloseWAL()
+
+	entdb_new := NewEntDB(path)
+	if err := entdb_new.Load(); err != nil {
+		t.Fatalf("test load with wal segment failed: %v", err)
+	}
+
+	Expected := 5
+	Got := len(entdb_new.Items)
+	if Got != Expected {
+		t.Errorf("test wal segment replay items count failed: got %v, wanted %v", Got, Expected)
+	}
+	if _, err := entdb_new.GetVideoById(uint(1)); err == nil {
+		t.Errorf("test wal segment replay order failed: video 1 should be removed")
+	}
+}
+
+/*
+Every public query runs alongside Add/Update/Remove, run with -race
+*/
+func TestEntDBConcurrentQueries(t *testing.T) {
+	entdb := NewEntDB(t.TempDir())
+	entdb.AddTag(NewTag(1, "tag 1"))
+	entdb.AddModel(NewModel(1, "model 1"))
+
+	newVideo := func(id int) *EntVideo {
+		video := NewEntVideo(entdb)
+		video.Id = uint(id)
+		video.Title = fmt.Sprintf("hot title number %d", id)
+		video.Slug = fmt.Sprintf("hot-title-number-%d", id)
+		video.AddTag(entdb.DictTags[1])
+		video.AddModel(entdb.DictModels[1])
+		video.AddKeyword(NewKeyword(id, fmt.Sprintf("hot keyword %d", id)))
+		return video
+	}
+
+	for id := 1; id <= 10; id++ {
+		entdb.Add(newVideo(id))
+	}
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(done)
+		for id := 11; id <= 500; id++ {
+			entdb.Add(newVideo(id))
+			entdb.Update(newVideo(id - 5))
+			if id%3 == 0 {
+				entdb.Remove(uint(id - 1))
+			}
+		}
+	}()
+
+	queries := []func(){
+		func() { entdb.RandomSetByTag("tag-1", 5) },
+		func() { entdb.RandomSetByTag("tag-1", 1000) },
+		func() { entdb.RandomSetByModel("model-1", 5) },
+		func() { entdb.RandomSetByModel("model-1", 1000) },
+		func() { entdb.RandomSetBySearch("hot title", 10) },
+		func() { entdb.RelevantBySearch("hot-title-number", 10) },
+		func() { entdb.GetRelevantForVideoBySearch(newVideo(1), 10) },
+		func() { entdb.GetKeywordsRelatedSet(newVideo(1), 5, false, nil) },
+		func() { entdb.GetKeywordsRandomSet(5, false, nil) },
+		func() { entdb.RandomSet(5) },
+	}
+
+	for _, query := range queries {
+		wg.Add(1)
+		go func(query func()) {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+					query()
+				}
+			}
+		}(query)
+	}
+
+	wg.Wait()
+}
@@ -0,0 +1,217 @@
+package goentdb
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+/*
+Checks a freshly loaded EntDB before Reload swaps it in
+*/
+type EntReloadValidator func(fresh *EntDB) error
+
+var ErrNothingToRollback = errors.New("no previous snapshot to roll back to")
+
+/*
+Load the snapshot in StoragePath into a fresh EntDB and swap it in.
+Readers keep using the current index until the swap, the replaced state
+is kept for Rollback. Writers are only blocked while the WAL is replayed
+on top of the fresh state and the state is swapped.
+*/
+func (edb *EntDB) Reload() (*EntLoadReport, error) {
+	edb.dumpLock.Lock()
+	defer edb.dumpLock.Unlock()
+
+	fresh := edb.newFresh()
+	report := &EntLoadReport{Policy: fresh.LoadPolicy}
+	fresh.loadSnapshot(report)
+	if err := report.Err(); err != nil {
+		return report, err
+	}
+
+	if err := edb.validateReload(fresh); err != nil {
+		return report, fmt.Errorf("reload of %s refused: %w", edb.StoragePath, err)
+	}
+
+	for _, video := range fresh.Items {
+		video.Owner = edb
+	}
+
+	edb.lock.Lock()
+	defer edb.lock.Unlock()
+
+	// Replayed once with the writers blocked, so mutations logged while the
+	// snapshot was loading are included. Only the records logged on a state
+	// at least as new as the snapshot are applied.
+	if err := fresh.replayWAL(report); err != nil {
+		report.addFileError(fresh.GetWALPath(), err)
+		return report, report.Err()
+	}
+	fresh.writeQuarantine(report)
+
+	edb.swapState(fresh)
+	edb.generation = fresh.generation
+	edb.previous = fresh
+
+	return report, nil
+}
+
+func (edb *EntDB) validateReload(fresh *EntDB) error {
+	edb.lock.RLock()
+	current := len(edb.Items)
+	edb.lock.RUnlock()
+
+	if len(fresh.Items) == 0 && current > 0 {
+		return fmt.Errorf("snapshot is empty, %d videos are loaded", current)
+	}
+
+	if edb.ReloadCheck != nil {
+		return edb.ReloadCheck(fresh)
+	}
+
+	return nil
+}
+
+/*
+Swap back the state replaced by the last Reload, calling it again redoes the Reload.
+Only the memory is swapped, the files in StoragePath are not touched: mutations
+logged afterwards are replayed on top of the snapshot Reload loaded.
+*/
+func (edb *EntDB) Rollback() error {
+	edb.dumpLock.Lock()
+	defer edb.dumpLock.Unlock()
+
+	edb.lock.Lock()
+	defer edb.lock.Unlock()
+
+	if edb.previous == nil {
+		return ErrNothingToRollback
+	}
+
+	edb.swapState(edb.previous)
+
+	return nil
+}
+
+/*
+Exchange the indexes of edb and other and publish the new ones,
+caller holds dumpLock and the lock of edb.
+The snapshot generation is not exchanged: WAL records are stamped with the
+generation committed on disk, which a Rollback does not move back.
+*/
+func (edb *EntDB) swapState(other *EntDB) {
+	edb.Items, other.Items = other.Items, edb.Items
+	edb.SeoPool, other.SeoPool = other.SeoPool, edb.SeoPool
+	edb.Tags, other.Tags = other.Tags, edb.Tags
+	edb.Models, other.Models = other.Models, edb.Models
+	edb.Search, other.Search = other.Search, edb.Search
+	edb.Keywords, other.Keywords = other.Keywords, edb.Keywords
+	edb.DictTags, other.DictTags = other.DictTags, edb.DictTags
+	edb.DictModels, other.DictModels = other.DictModels, edb.DictModels
+	edb.DictVideos, other.DictVideos = other.DictVideos, edb.DictVideos
+	edb.TwoGrams, other.TwoGrams = other.TwoGrams, edb.TwoGrams
+	edb.ThreeGrams, other.ThreeGrams = other.ThreeGrams, edb.ThreeGrams
+	edb.Origins, other.Origins = other.Origins, edb.Origins
+	edb.redirects, other.redirects = other.redirects, edb.redirects
+	edb.stamp, other.stamp = other.stamp, edb.stamp
+	edb.keywordEdits++
+
+	// Generations keep growing across swaps so readers can tell them apart
+	generation := edb.next.Generation
+	edb.next, other.next = other.next, edb.next
+	if edb.next.Generation < generation {
+		edb.next.Generation = generation
+	}
+
+	edb.dirty = true
+	edb.publish()
+}
+
+/*
+Identifies the snapshot in StoragePath: the manifest generation, or size and
+modification time of the standalone files for storages without a manifest
+*/
+func (edb *EntDB) storageStamp() (string, error) {
+	manifest, err := edb.ReadManifest()
+	if err == nil {
+		return fmt.Sprintf("generation %d", manifest.Generation), nil
+	}
+	if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	stamp := "standalone"
+	for _, path := range []string{edb.GetDictTagsPath(), edb.GetDictModelsPath(), edb.GetDictVideosPath()} {
+		stat, err := os.Stat(path)
+		if err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+		if err == nil {
+			stamp += fmt.Sprintf(" %d@%d", stat.Size(), stat.ModTime().UnixNano())
+		}
+	}
+
+	return stamp, nil
+}
+
+/*
+Poll StoragePath every interval and Reload once another process wrote a new snapshot.
+Standalone files are written one after another, so a change is only picked up
+once it stayed the same for a whole interval. A snapshot which failed to
+reload is not tried again until it changes. done, if not nil, gets the
+outcome of every Reload. The returned func stops the watcher.
+*/
+func (edb *EntDB) WatchReload(interval time.Duration, done func(report *EntLoadReport, err error)) (stop func()) {
+	quit := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		pending, failed := "", ""
+		for {
+			select {
+			case <-quit:
+				return
+			case <-ticker.C:
+			}
+
+			stamp, err := edb.storageStamp()
+			if err != nil {
+				continue
+			}
+
+			edb.dumpLock.Lock()
+			current := edb.stamp
+			edb.dumpLock.Unlock()
+
+			if stamp == current || stamp == failed {
+				pending = ""
+				continue
+			}
+			if stamp != pending {
+				pending = stamp
+				continue
+			}
+
+			pending = ""
+			report, err := edb.Reload()
+			if err != nil {
+				failed = stamp
+			}
+			if done != nil {
+				done(report, err)
+			}
+		}
+	}()
+
+	return func() {
+		close(quit)
+		<-stopped
+	}
+}
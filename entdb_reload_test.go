@@ -0,0 +1,233 @@
+package goentdb
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+/*
+Snapshot written by another process, e.g. the nightly scraper
+*/
+func DumpScraperSnapshot(t *testing.T, path string, videos int) {
+	scraper := NewEntDB(path)
+	scraper.AddTag(NewTag(1, "tag 1"))
+
+	for i := 1; i <= videos; i++ {
+		video := NewEntVideo(scraper)
+		video.Id = uint(i)
+		video.Title = fmt.Sprintf("title number %d", i)
+		video.Slug = fmt.Sprintf("title-number-%d", i)
+		video.AddTag(scraper.DictTags[1])
+		scraper.Add(video)
+	}
+
+	if err := scraper.Dump(); err != nil {
+		t.Fatalf("test scraper dump failed: %v", err)
+	}
+}
+
+func TestEntDBReload(t *testing.T) {
+	path := t.TempDir()
+	DumpScraperSnapshot(t, path, 3)
+
+	entdb := NewEntDB(path)
+	if err := entdb.Load(); err != nil {
+		t.Fatalf("test load failed: %v", err)
+	}
+
+	if err := entdb.Rollback(); !errors.Is(err, ErrNothingToRollback) {
+		t.Errorf("test rollback without reload failed: got %v, wanted %v", err, ErrNothingToRollback)
+	}
+
+	before := entdb.Index()
+
+	DumpScraperSnapshot(t, path, 5)
+	if _, err := entdb.Reload(); err != nil {
+		t.Fatalf("test reload failed: %v", err)
+	}
+
+	Expected := 5
+	Got := entdb.Index().Len()
+	if Got != Expected {
+		t.Errorf("test reload items count failed: got %v, wanted %v", Got, Expected)
+	}
+	if len(entdb.Items) != Expected {
+		t.Errorf("test reload writer items count failed: got %v, wanted %v", len(entdb.Items), Expected)
+	}
+	if entdb.Index().Generation <= before.Generation {
+		t.Errorf("test reload generation failed: got %v, wanted > %v", entdb.Index().Generation, before.Generation)
+	}
+
+	// In-flight readers keep the index they started with
+	if before.Len() != 3 {
+		t.Errorf("test reload old index failed: got %v, wanted %v", before.Len(), 3)
+	}
+
+	video, _ := entdb.GetVideoById(uint(5))
+	if video == nil || video.Owner != entdb {
+		t.Errorf("test reload video owner failed: got %v", video)
+	}
+
+	if err := entdb.Rollback(); err != nil {
+		t.Fatalf("test rollback failed: %v", err)
+	}
+
+	Expected = 3
+	Got = entdb.Index().Len()
+	if Got != Expected {
+		t.Errorf("test rollback items count failed: got %v, wanted %v", Got, Expected)
+	}
+	if _, err := entdb.GetVideoById(uint(5)); err == nil {
+		t.Errorf("test rollback should drop video 5")
+	}
+}
+
+func TestEntDBReloadRefused(t *testing.T) {
+	path := t.TempDir()
+	DumpScraperSnapshot(t, path, 3)
+
+	entdb := NewEntDB(path)
+	entdb.Load()
+	entdb.ReloadCheck = func(fresh *EntDB) error {
+		if len(fresh.Items) < 10 {
+			return fmt.Errorf("only %d videos", len(fresh.Items))
+		}
+		return nil
+	}
+
+	DumpScraperSnapshot(t, path, 5)
+	if _, err := entdb.Reload(); err == nil {
+		t.Errorf("test reload should be refused by ReloadCheck")
+	}
+
+	Expected := 3
+	Got := entdb.Index().Len()
+	if Got != Expected {
+		t.Errorf("test refused reload items count failed: got %v, wanted %v", Got, Expected)
+	}
+}
+
+func TestEntDBReloadEmptyRefused(t *testing.T) {
+	entdb := GenerateSnapshotEntDB(t.TempDir())
+
+	// Nothing was dumped, the storage holds no snapshot
+	if _, err := entdb.Reload(); err == nil {
+		t.Errorf("test reload of an empty snapshot should be refused")
+	}
+
+	Expected := 5
+	Got := entdb.Index().Len()
+	if Got != Expected {
+		t.Errorf("test empty reload items count failed: got %v, wanted %v", Got, Expected)
+	}
+}
+
+func TestEntDBWatchReload(t *testing.T) {
+	path := t.TempDir()
+	DumpScraperSnapshot(t, path, 3)
+
+	entdb := NewEntDB(path)
+	entdb.Load()
+
+	reloaded := make(chan error, 1)
+	stop := entdb.WatchReload(10*time.Millisecond, func(report *EntLoadReport, err error) {
+		reloaded <- err
+	})
+	defer stop()
+
+	DumpScraperSnapshot(t, path, 7)
+
+	select {
+	case err := <-reloaded:
+		if err != nil {
+			t.Fatalf("test watch reload failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("test watch reload timed out")
+	}
+
+	Expected := 7
+	Got := entdb.Index().Len()
+	if Got != Expected {
+		t.Errorf("test watch reload items count failed: got %v, wanted %v", Got, Expected)
+	}
+}
+
+func TestEntDBReloadWAL(t *testing.T) {
+	path := t.TempDir()
+	DumpScraperSnapshot(t, path, 3)
+
+	entdb := NewEntDB(path)
+	entdb.Load()
+	entdb.OpenWAL()
+	defer entdb.CloseWAL()
+
+	video := NewEntVideo(entdb)
+	video.Id = 100
+	video.Title = "local video"
+	video.Slug = "local-video"
+	entdb.Add(video)
+
+	// The scraper snapshot is newer than the state the local record was logged on
+	DumpScraperSnapshot(t, path, 5)
+	if _, err := entdb.Reload(); err != nil {
+		t.Fatalf("test reload wal failed: %v", err)
+	}
+	if _, err := entdb.GetVideoById(100); err == nil {
+		t.Errorf("test reload wal should skip records older than the snapshot")
+	}
+
+	// Records logged after the reload are replayed on top of it
+	video = NewEntVideo(entdb)
+	video.Id = 101
+	video.Title = "local video after reload"
+	video.Slug = "local-video-after-reload"
+	entdb.Add(video)
+
+	if _, err := entdb.Reload(); err != nil {
+		t.Fatalf("test reload wal again failed: %v", err)
+	}
+	if Got := entdb.Index().Len(); Got != 6 {
+		t.Errorf("test reload wal items count failed: got %v, wanted %v", Got, 6)
+	}
+	if _, err := entdb.GetVideoById(101); err != nil {
+		t.Errorf("test reload wal lost a record newer than the snapshot: %v", err)
+	}
+}
+
+func TestEntDBRollbackWAL(t *testing.T) {
+	path := t.TempDir()
+	DumpScraperSnapshot(t, path, 3)
+
+	entdb := NewEntDB(path)
+	entdb.Load()
+	entdb.OpenWAL()
+
+	DumpScraperSnapshot(t, path, 5)
+	if _, err := entdb.Reload(); err != nil {
+		t.Fatalf("test rollback wal reload failed: %v", err)
+	}
+	if err := entdb.Rollback(); err != nil {
+		t.Fatalf("test rollback wal failed: %v", err)
+	}
+
+	video := NewEntVideo(entdb)
+	video.Id = 100
+	video.Title = "local video"
+	video.Slug = "local-video"
+	if err := entdb.Add(video); err != nil {
+		t.Fatalf("test rollback wal add failed: %v", err)
+	}
+	entdb.CloseWAL()
+
+	// Records logged after the rollback are not older than the snapshot on disk
+	reopened := NewEntDB(path)
+	if err := reopened.Load(); err != nil {
+		t.Fatalf("test rollback wal reopen failed: %v", err)
+	}
+	if _, err := reopened.GetVideoById(100); err != nil {
+		t.Errorf("test rollback wal lost a record logged after the rollback: %v", err)
+	}
+}
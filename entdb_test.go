@@ -199,9 +199,12 @@ func TestEntDBGetVideo(t *testing.T) {
 	if err == nil {
 		t.Errorf("test get video by md5 failed: %v", err)
 	}
-	ExpectedErr := "EntVideo not found"
-	if err.Error() != ExpectedErr {
-		t.Errorf("test get error when video not found by md5 failed: got %v, wanted %v", err, ExpectedErr)
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("test get error when video not found by md5 failed: got %v, wanted %v", err, ErrNotFound)
+	}
+	var entErr *EntError
+	if !errors.As(err, &entErr) || entErr.Kind != EntKindVideo || entErr.Key != MD5("not-existing-video") {
+		t.Errorf("test get typed error when video not found by md5 failed: got %#v", err)
 	}
 
 	Got, err = entdb.GetVideoByMD5(MD5("aaa-bbb-ccc"))
@@ -299,3 +302,155 @@ func TestEntDBDumpLoadModels(t *testing.T) {
 		}
 	}
 }
+
+func TestEntDBRemove(t *testing.T) {
+	entdb := NewEntDB("/tmp")
+
+	videos := GenerateEntVideos(entdb)
+
+	for _, video := range videos {
+		entdb.Add(video)
+	}
+
+	if err := entdb.Remove(uint(123456)); err != nil {
+		t.Errorf("test remove video failed: %v", err)
+	}
+
+	Expected := 5
+	Got := len(entdb.Items)
+	if Got != Expected {
+		t.Errorf("test remove video items count failed: got %v, wanted %v", Got, Expected)
+	}
+
+	Expected = 5
+	Got = len(entdb.Tags)
+	if Got != Expected {
+		t.Errorf("test remove video tags count failed: got %v, wanted %v", Got, Expected)
+	}
+
+	Expected = 1
+	Got = len(entdb.Tags["tag-2"])
+	if Got != Expected {
+		t.Errorf("test remove video tag-2 count failed: got %v, wanted %v", Got, Expected)
+	}
+
+	Expected = 4
+	Got = len(entdb.Models)
+	if Got != Expected {
+		t.Errorf("test remove video models count failed: got %v, wanted %v", Got, Expected)
+	}
+
+	Expected = 9
+	Got = len(entdb.Keywords)
+	if Got != Expected {
+		t.Errorf("test remove video keywords count failed: got %v, wanted %v", Got, Expected)
+	}
+
+	if _, err := entdb.GetVideoByMD5(MD5("aaa-bbb-ccc")); err == nil {
+		t.Errorf("test remove video keyword md5 should be gone")
+	}
+
+	if Got, err := entdb.GetVideoByMD5(MD5("bbb-ccc-ddd")); err != nil || Got != videos[1] {
+		t.Errorf("test remove video shared keyword failed: got %v, wanted %v", Got, videos[1])
+	}
+
+	if _, err := entdb.GetVideoById(uint(123456)); err == nil {
+		t.Errorf("test remove video by id should be gone")
+	}
+
+	Expected = 5
+	Got = len(entdb.Search[entdb.Analyzer().Stem("title")])
+	if Got != Expected {
+		t.Errorf("test remove video search count failed: got %v, wanted %v", Got, Expected)
+	}
+
+	if _, exists := entdb.TwoGrams["number 1"]; exists {
+		t.Errorf("test remove video 2-gram should be gone")
+	}
+
+	if _, exists := entdb.ThreeGrams["title number 1"]; exists {
+		t.Errorf("test remove video 3-gram should be gone")
+	}
+
+	Expected = 5
+	Got = entdb.Origins[OriginUnkown]
+	if Got != Expected {
+		t.Errorf("test remove video origins count failed: got %v, wanted %v", Got, Expected)
+	}
+
+	if err := entdb.Remove(uint(123456)); err == nil {
+		t.Errorf("test remove missing video should fail")
+	}
+}
+
+func TestEntDBUpdate(t *testing.T) {
+	entdb := NewEntDB("/tmp")
+
+	videos := GenerateEntVideos(entdb)
+
+	for _, video := range videos {
+		entdb.Add(video)
+	}
+
+	video := NewEntVideo(entdb)
+	video.Id = uint(123456)
+	video.Title = "fixed headline 1"
+	video.Slug = "fixed-headline-1"
+	video.Origin = OriginXvideos
+	video.Tags = []*EntKeyword{
+		{Phrase: "tag 7", Type: EntKeywordTag},
+	}
+
+	if err := entdb.Update(video); err != nil {
+		t.Errorf("test update video failed: %v", err)
+	}
+
+	Expected := 6
+	Got := len(entdb.Items)
+	if Got != Expected {
+		t.Errorf("test update video items count failed: got %v, wanted %v", Got, Expected)
+	}
+
+	if _, exists := entdb.Tags["tag-1"]; exists {
+		t.Errorf("test update video old tag should be gone")
+	}
+
+	Expected = 1
+	Got = len(entdb.Tags["tag-7"])
+	if Got != Expected {
+		t.Errorf("test update video new tag count failed: got %v, wanted %v", Got, Expected)
+	}
+
+	if _, exists := entdb.Search[entdb.Analyzer().Stem("headline")]; !exists {
+		t.Errorf("test update video new title token should be indexed")
+	}
+
+	if _, exists := entdb.TwoGrams["number 1"]; exists {
+		t.Errorf("test update video old 2-gram should be gone")
+	}
+
+	if Got, err := entdb.GetVideoByMD5(MD5("fixed-headline-1")); err != nil || Got != video {
+		t.Errorf("test update video by md5 failed: got %v, wanted %v", Got, video)
+	}
+
+	Expected = 1
+	Got = entdb.Origins[OriginXvideos]
+	if Got != Expected {
+		t.Errorf("test update video origins count failed: got %v, wanted %v", Got, Expected)
+	}
+
+	missing := NewEntVideo(entdb)
+	missing.Id = uint(1)
+	if err := entdb.Update(missing); err == nil {
+		t.Errorf("test update missing video should fail")
+	}
+
+	// The stored video changed in place can not be told apart from its old version
+	video.Title = "changed in place"
+	if err := entdb.Update(video); !errors.Is(err, ErrVideoInPlace) {
+		t.Errorf("test update stored video failed: got %v, wanted %v", err, ErrVideoInPlace)
+	}
+	if _, exists := entdb.Search[entdb.Analyzer().Stem("headline")]; !exists {
+		t.Errorf("test update stored video should keep the index")
+	}
+}
@@ -0,0 +1,222 @@
+package goentdb
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+/*
+Start logging every mutation to the WAL under StoragePath
+Call it after Load so the records already in the log are not applied twice.
+*/
+func (edb *EntDB) OpenWAL() error {
+	edb.lock.Lock()
+	defer edb.lock.Unlock()
+
+	if edb.wal != nil {
+		return nil
+	}
+
+	wal, err := OpenEntWAL(edb.GetWALPath())
+	if err != nil {
+		return err
+	}
+	edb.wal = wal
+
+	return nil
+}
+
+func (edb *EntDB) CloseWAL() error {
+	edb.lock.Lock()
+	defer edb.lock.Unlock()
+
+	if edb.wal == nil {
+		return nil
+	}
+
+	err := edb.wal.Close()
+	edb.wal = nil
+
+	return err
+}
+
+/*
+Records moved aside by a checkpoint which did not finish
+*/
+func (edb *EntDB) GetWALSegmentPath() string {
+	return fmt.Sprintf("%s/wal.checkpoint", edb.StoragePath)
+}
+
+/*
+Apply the records of the WAL on top of the loaded snapshot, a segment left
+by an unfinished checkpoint is older and goes first. Records logged on a
+state older than the snapshot generation are skipped: they are in the
+snapshot already, or the snapshot was written by another process and
+replaces them.
+*/
+func (edb *EntDB) ReplayWAL() error {
+	return edb.replayWAL(&EntLoadReport{Policy: edb.LoadPolicy})
+}
+
+/*
+Video records with dangling tags or models are handled by the policy of
+report like the records of a snapshot
+*/
+func (edb *EntDB) replayWAL(report *EntLoadReport) error {
+	edb.lock.Lock()
+	defer edb.lock.Unlock()
+	defer edb.publish()
+
+	apply := func(rec *EntWALRecord) error {
+		if rec.Generation != 0 && rec.Generation < edb.generation {
+			return nil
+		}
+		if rec.Video != nil && !edb.checkReferences(rec.Video, report) {
+			report.reject(rec.Video)
+			if report.Policy == LoadPolicyFail {
+				return fmt.Errorf("video %d has dangling tags or models", rec.Video.Id)
+			}
+			return nil
+		}
+		return edb.applyWALRecord(rec)
+	}
+
+	for _, path := range []string{edb.GetWALSegmentPath(), edb.GetWALPath()} {
+		_, err := ReadEntWAL(path, apply)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+/*
+Write a fresh snapshot and empty the WAL
+*/
+func (edb *EntDB) CompactWAL() error {
+	return edb.checkpoint(false)
+}
+
+func (edb *EntDB) compactWALIfNeeded() {
+	edb.checkpoint(true)
+}
+
+/*
+Failure of the last compaction a mutation started once the WAL grew past
+WALThreshold, nil again after a compaction or Dump succeeds. The mutation
+itself was committed, its WAL keeps growing until a compaction succeeds.
+*/
+func (edb *EntDB) CompactionErr() error {
+	edb.dumpLock.Lock()
+	defer edb.dumpLock.Unlock()
+
+	return edb.compactErr
+}
+
+/*
+Caller holds the lock
+*/
+func (edb *EntDB) walNeedsCompaction() bool {
+	return edb.wal != nil && edb.WALThreshold > 0 && edb.wal.Size() >= edb.WALThreshold
+}
+
+/*
+Caller holds the lock
+*/
+func (edb *EntDB) rotateWAL() error {
+	if edb.wal == nil {
+		return nil
+	}
+
+	return edb.wal.Rotate(edb.GetWALSegmentPath())
+}
+
+/*
+Run a logged mutation under the lock, the WAL is compacted after the lock
+is released so writers are not blocked while the snapshot is written.
+A failed compaction does not fail the committed mutation, see CompactionErr.
+*/
+func (edb *EntDB) mutate(fn func() error) error {
+	edb.lock.Lock()
+	err := fn()
+	edb.publish()
+	edb.lock.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	edb.compactWALIfNeeded()
+
+	return nil
+}
+
+/*
+Records are appended as one write, none of them is logged when it fails
+*/
+func (edb *EntDB) logMutation(recs ...*EntWALRecord) error {
+	if edb.wal == nil {
+		return nil
+	}
+
+	for _, rec := range recs {
+		rec.Generation = edb.generation
+	}
+
+	return edb.wal.Append(recs...)
+}
+
+func (edb *EntDB) logVideoMutation(op EntWALOp, video *EntVideo) error {
+	if edb.wal == nil {
+		return nil
+	}
+
+	return edb.logMutation(videoRecord(op, video))
+}
+
+func videoRecord(op EntWALOp, video *EntVideo) *EntWALRecord {
+	evfl := video.ToLoad()
+	return &EntWALRecord{Op: op, Video: &evfl}
+}
+
+/*
+Replay is idempotent: a video which is already in the snapshot is replaced,
+this covers a crash between writing the snapshot and emptying the WAL.
+*/
+func (edb *EntDB) applyWALRecord(rec *EntWALRecord) error {
+	switch rec.Op {
+	case EntWALAddTag:
+		edb.DictTags[rec.Keyword.Id] = rec.Keyword
+		edb.indexTag(rec.Keyword.Id)
+	case EntWALAddModel:
+		edb.DictModels[rec.Keyword.Id] = rec.Keyword
+		edb.indexModel(rec.Keyword.Id)
+	case EntWALAddVideo, EntWALUpdateVideo:
+		video, err := edb.videoFromLoad(rec.Video)
+		if err != nil {
+			return err
+		}
+		if old, exists := edb.DictVideos[video.Id]; exists {
+			edb.remove(old)
+		}
+		edb.add(video)
+	case EntWALRemoveVideo:
+		if video, exists := edb.DictVideos[rec.Id]; exists {
+			edb.remove(video)
+		}
+	case EntWALRenameTag:
+		edb.replayRename(edb.tagDict(), rec.Keyword)
+	case EntWALRenameModel:
+		edb.replayRename(edb.modelDict(), rec.Keyword)
+	case EntWALMergeTags:
+		edb.replayMerge(edb.tagDict(), rec.Keyword.Id, rec.Ids)
+	case EntWALMergeModels:
+		edb.replayMerge(edb.modelDict(), rec.Keyword.Id, rec.Ids)
+	default:
+		return fmt.Errorf("unknown wal op %d", rec.Op)
+	}
+
+	return nil
+}
@@ -0,0 +1,230 @@
+package goentdb
+
+import (
+	"sort"
+	"time"
+)
+
+type EntInterval uint8
+
+const (
+	IntervalNone EntInterval = iota
+	IntervalDay
+	IntervalWeek // Weeks start on Monday
+	IntervalMonth
+	IntervalYear
+)
+
+/*
+Upper bounds in seconds of short, medium and long videos
+*/
+var DefaultDurationBuckets = []int{300, 600, 1200, 1800}
+
+/*
+Facets to aggregate over a result set, zero fields are skipped
+*/
+type EntFacetRequest struct {
+	TopTags         int         // Most frequent tags
+	TopModels       int         // Most frequent models
+	Origins         bool        // Count of every origin
+	DurationBuckets []int       // Ascending upper bounds in seconds, the last bucket has no upper bound
+	ModifiedBy      EntInterval // Histogram of ModifiedAt
+}
+
+type EntFacetCount struct {
+	Value   string      // Slug of a tag or model, name of an origin
+	Keyword *EntKeyword // Tag or model, nil for origins
+	Count   int
+}
+
+type EntDurationBucket struct {
+	From  int // Seconds, inclusive
+	To    int // Seconds, exclusive, 0 for the last bucket
+	Count int
+}
+
+type EntDateBucket struct {
+	Start time.Time // Start of the interval in UTC
+	Count int
+}
+
+type EntFacets struct {
+	Tags      []EntFacetCount
+	Models    []EntFacetCount
+	Origins   []EntFacetCount
+	Durations []EntDurationBucket
+	Modified  []EntDateBucket // Only intervals with videos, oldest first
+}
+
+/*
+Slug a keyword is counted under and the keyword shown for it
+*/
+type entFacetKey func(keyword *EntKeyword) (string, *EntKeyword)
+
+func keywordFacetKey(keyword *EntKeyword) (string, *EntKeyword) {
+	return keyword.GetSlug(), keyword
+}
+
+/*
+Aggregate the facets of req over every video in results.
+A video counts once for each of its tags and models, see EntIndex.Facets
+to count aliases and renamed keywords as their canonical keyword.
+*/
+func Facets(results []EntSearchResult, req EntFacetRequest) *EntFacets {
+	return facets(results, req, keywordFacetKey, keywordFacetKey)
+}
+
+/*
+Facets with tags and models counted under the slug ByTag and ByModel list
+them under: aliases as their canonical keyword, old slugs as their redirect
+*/
+func (idx *EntIndex) Facets(results []EntSearchResult, req EntFacetRequest) *EntFacets {
+	tagKey := func(tag *EntKeyword) (string, *EntKeyword) {
+		tag = idx.CanonicalTag(tag)
+		if to, exists := idx.TagRedirect(tag.GetSlug()); exists {
+			return to, tag
+		}
+		return tag.GetSlug(), tag
+	}
+	modelKey := func(model *EntKeyword) (string, *EntKeyword) {
+		model = idx.CanonicalModel(model)
+		if to, exists := idx.ModelRedirect(model.GetSlug()); exists {
+			return to, model
+		}
+		return model.GetSlug(), model
+	}
+
+	return facets(results, req, tagKey, modelKey)
+}
+
+func facets(results []EntSearchResult, req EntFacetRequest, tagKey, modelKey entFacetKey) *EntFacets {
+	facets := &EntFacets{}
+
+	tags := make(map[string]*EntFacetCount)
+	models := make(map[string]*EntFacetCount)
+	origins := make(map[string]*EntFacetCount)
+	modified := make(map[time.Time]int)
+
+	for _, bound := range req.DurationBuckets {
+		from := 0
+		if len(facets.Durations) > 0 {
+			from = facets.Durations[len(facets.Durations)-1].To
+		}
+		facets.Durations = append(facets.Durations, EntDurationBucket{From: from, To: bound})
+	}
+	if len(facets.Durations) > 0 {
+		facets.Durations = append(facets.Durations, EntDurationBucket{From: facets.Durations[len(facets.Durations)-1].To})
+	}
+
+	for _, result := range results {
+		video := result.Video
+
+		if req.TopTags > 0 {
+			countKeywords(tags, video.Tags, tagKey)
+		}
+		if req.TopModels > 0 {
+			countKeywords(models, video.Models, modelKey)
+		}
+		if req.Origins {
+			name := video.Origin.String()
+			if origins[name] == nil {
+				origins[name] = &EntFacetCount{Value: name}
+			}
+			origins[name].Count++
+		}
+		if len(facets.Durations) > 0 {
+			pos := sort.Search(len(req.DurationBuckets), func(i int) bool {
+				return video.Duration < req.DurationBuckets[i]
+			})
+			facets.Durations[pos].Count++
+		}
+		if req.ModifiedBy != IntervalNone {
+			modified[truncateInterval(video.ModifiedAt, req.ModifiedBy)]++
+		}
+	}
+
+	facets.Tags = topFacets(tags, req.TopTags)
+	facets.Models = topFacets(models, req.TopModels)
+	facets.Origins = topFacets(origins, len(origins))
+
+	for start, count := range modified {
+		facets.Modified = append(facets.Modified, EntDateBucket{Start: start, Count: count})
+	}
+	sort.Slice(facets.Modified, func(i, j int) bool {
+		return facets.Modified[i].Start.Before(facets.Modified[j].Start)
+	})
+
+	return facets
+}
+
+/*
+Count the keywords of one video, each slug once
+*/
+func countKeywords(counts map[string]*EntFacetCount, keywords []*EntKeyword, key entFacetKey) {
+	seen := make(map[string]bool, len(keywords))
+	for _, keyword := range keywords {
+		slug, keyword := key(keyword)
+		if seen[slug] {
+			continue
+		}
+		seen[slug] = true
+
+		count := counts[slug]
+		if count == nil {
+			count = &EntFacetCount{Value: slug, Keyword: keyword}
+			counts[slug] = count
+		}
+		// Prefer the keyword which has the slug over one redirected to it
+		if count.Keyword.GetSlug() != slug && keyword.GetSlug() == slug {
+			count.Keyword = keyword
+		}
+		count.Count++
+	}
+}
+
+/*
+Size most frequent values, ties ordered by value
+*/
+func topFacets(counts map[string]*EntFacetCount, Size int) []EntFacetCount {
+	res := make([]EntFacetCount, 0, len(counts))
+	for _, count := range counts {
+		res = append(res, *count)
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Count != res[j].Count {
+			return res[i].Count > res[j].Count
+		}
+		return res[i].Value < res[j].Value
+	})
+
+	return res[:Min(len(res), Size)]
+}
+
+func truncateInterval(t time.Time, interval EntInterval) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+
+	switch interval {
+	case IntervalWeek:
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	case IntervalMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	case IntervalYear:
+		return time.Date(t.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return day
+}
+
+/*
+Facets of the videos matching the boolean Query, see ParseQuery
+*/
+func (edb *EntDB) QueryFacets(Query string, req EntFacetRequest) (*EntFacets, error) {
+	idx := edb.Index()
+	query, err := idx.Analyzer().ParseQuery(Query)
+	if err != nil {
+		return nil, err
+	}
+
+	return idx.Facets(idx.Query(query, edb.Ranking), req), nil
+}
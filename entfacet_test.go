@@ -0,0 +1,133 @@
+package goentdb
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFacets(t *testing.T) {
+	entdb := GenerateQueryEntDB(t.TempDir())
+
+	facets, err := entdb.QueryFacets(`-"phrase exact"`, EntFacetRequest{
+		TopTags:         1,
+		TopModels:       5,
+		Origins:         true,
+		DurationBuckets: []int{600, 1000},
+		ModifiedBy:      IntervalMonth,
+	})
+	if err != nil {
+		t.Fatalf("test query facets failed: %v", err)
+	}
+
+	if len(facets.Tags) != 1 || facets.Tags[0].Value != "blonde" || facets.Tags[0].Count != 3 {
+		t.Errorf("test tag facet failed: got %v", facets.Tags)
+	}
+	if len(facets.Models) != 1 || facets.Models[0].Keyword.Phrase != "Jane Doe" || facets.Models[0].Count != 3 {
+		t.Errorf("test model facet failed: got %v", facets.Models)
+	}
+
+	ExpectedOrigins := []EntFacetCount{{Value: OriginXvideos.String(), Count: 3}, {Value: OriginEporner.String(), Count: 1}}
+	if len(facets.Origins) != len(ExpectedOrigins) {
+		t.Fatalf("test origin facet failed: got %v, wanted %v", facets.Origins, ExpectedOrigins)
+	}
+	for i, Expected := range ExpectedOrigins {
+		if facets.Origins[i] != Expected {
+			t.Errorf("test origin facet failed: got %v, wanted %v", facets.Origins[i], Expected)
+		}
+	}
+
+	ExpectedDurations := []EntDurationBucket{{0, 600, 1}, {600, 1000, 2}, {1000, 0, 1}}
+	for i, Expected := range ExpectedDurations {
+		if facets.Durations[i] != Expected {
+			t.Errorf("test duration facet failed: got %v, wanted %v", facets.Durations[i], Expected)
+		}
+	}
+
+	ExpectedModified := []EntDateBucket{
+		{time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), 1},
+		{time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), 1},
+		{time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), 2},
+	}
+	if len(facets.Modified) != len(ExpectedModified) {
+		t.Fatalf("test modified facet failed: got %v, wanted %v", facets.Modified, ExpectedModified)
+	}
+	for i, Expected := range ExpectedModified {
+		if !facets.Modified[i].Start.Equal(Expected.Start) || facets.Modified[i].Count != Expected.Count {
+			t.Errorf("test modified facet failed: got %v, wanted %v", facets.Modified[i], Expected)
+		}
+	}
+}
+
+func TestFacetsOfListing(t *testing.T) {
+	entdb := GenerateListEntDB(t.TempDir(), 25)
+
+	page, _ := entdb.List(EntListRequest{Tag: "tag-1", Limit: 5, Facets: &EntFacetRequest{TopTags: 10, ModifiedBy: IntervalWeek}})
+	if page.Facets == nil || len(page.Facets.Tags) != 1 || page.Facets.Tags[0].Count != 25 {
+		t.Fatalf("test listing facets failed: got %v", page.Facets)
+	}
+
+	// 2024-01-01 is a Monday, the videos are an hour apart
+	if len(page.Facets.Modified) != 1 || page.Facets.Modified[0].Count != 25 {
+		t.Errorf("test listing week facet failed: got %v", page.Facets.Modified)
+	}
+	if page.Facets.Durations != nil {
+		t.Errorf("test listing facets should skip durations: got %v", page.Facets.Durations)
+	}
+}
+
+func TestTruncateInterval(t *testing.T) {
+	moment := time.Date(2024, 3, 14, 15, 9, 26, 0, time.UTC) // Thursday
+
+	cases := []struct {
+		Interval EntInterval
+		Expected time.Time
+	}{
+		{IntervalDay, time.Date(2024, 3, 14, 0, 0, 0, 0, time.UTC)},
+		{IntervalWeek, time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC)},
+		{IntervalMonth, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{IntervalYear, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, c := range cases {
+		Got := truncateInterval(moment, c.Interval)
+		if !Got.Equal(c.Expected) {
+			t.Errorf("test truncate interval %v failed: got %v, wanted %v", c.Interval, Got, c.Expected)
+		}
+	}
+}
+
+func TestFacetsCanonical(t *testing.T) {
+	entdb := GenerateSynonymsEntDB(t.TempDir())
+
+	synonyms := NewEntSynonyms()
+	synonyms.AddTagAliases("Blonde", "Blond")
+	synonyms.AddModelAliases("Jane Doe", "Janie Doe")
+	entdb.SetSynonyms(synonyms)
+
+	// Aliases count as their canonical keyword, a video with both once
+	page, _ := entdb.List(EntListRequest{Facets: &EntFacetRequest{TopTags: 10, TopModels: 10}})
+	ExpectedTags := []EntFacetCount{{Value: "blonde", Count: 3}, {Value: "outdoor", Count: 1}}
+	if len(page.Facets.Tags) != len(ExpectedTags) {
+		t.Fatalf("test canonical tag facet failed: got %v, wanted %v", page.Facets.Tags, ExpectedTags)
+	}
+	for i, Expected := range ExpectedTags {
+		if Got := page.Facets.Tags[i]; Got.Value != Expected.Value || Got.Count != Expected.Count || Got.Keyword.GetSlug() != Expected.Value {
+			t.Errorf("test canonical tag facet failed: got %v, wanted %v", Got, Expected)
+		}
+	}
+	if Got := len(distinctVideos(entdb.Index().ByTag("blonde"))); Got != page.Facets.Tags[0].Count {
+		t.Errorf("test canonical tag facet should match the tag index: got %v, wanted %v", page.Facets.Tags[0].Count, Got)
+	}
+	if Got := page.Facets.Models; len(Got) != 1 || Got[0].Value != "jane-doe" || Got[0].Count != 2 {
+		t.Errorf("test canonical model facet failed: got %v", Got)
+	}
+
+	// A tag listed twice counts once
+	video, _ := entdb.GetVideoById(4)
+	repeated := *video
+	repeated.Tags = []*EntKeyword{video.Tags[0], video.Tags[0]}
+	facets := Facets([]EntSearchResult{{Video: &repeated}}, EntFacetRequest{TopTags: 10})
+	if len(facets.Tags) != 1 || facets.Tags[0].Count != 1 {
+		t.Errorf("test facet of repeated tag failed: got %v", facets.Tags)
+	}
+}
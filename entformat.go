@@ -0,0 +1,385 @@
+package goentdb
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+type EntFileKind uint8
+
+const (
+	EntFileUnknown EntFileKind = iota
+	EntFileKeywords
+	EntFileVideos
+	EntFileRedirects
+)
+
+/*
+Version of the snapshot files written by this package.
+Bump it together with a RegisterMigration from the previous version.
+
+	0: headerless gob written before the header existed
+	1: header + gob payload, videos as one []EntVideoForLoad
+	2: videos as a stream of EntVideoForLoad records
+	3: header records the codec the payload is compressed with
+*/
+const EntFormatVersion uint16 = 3
+
+var EntFileMagic = [8]byte{'G', 'O', 'E', 'N', 'T', 'D', 'B', 0}
+
+type EntFileHeader struct {
+	Version   uint16
+	Kind      EntFileKind
+	Codec     EntCodecId
+	Count     uint64 // Number of records in the payload
+	CreatedAt time.Time
+}
+
+type entFileHeaderPrefix struct {
+	Magic   [8]byte
+	Version uint16
+}
+
+type entFileHeaderV1 struct {
+	Kind      EntFileKind
+	Count     uint64
+	CreatedAt int64
+}
+
+type entFileHeaderV3 struct {
+	Kind      EntFileKind
+	Codec     EntCodecId
+	Count     uint64
+	CreatedAt int64
+}
+
+/*
+Migration upgrades the payload of a file from hdr.Version to hdr.Version+1.
+It returns a reader of the upgraded payload and updates hdr in place.
+*/
+type EntMigration func(hdr *EntFileHeader, payload io.Reader) (io.Reader, error)
+
+var (
+	migrationsLock sync.RWMutex
+	migrations     = map[uint16]EntMigration{
+		0: migrateHeaderless,
+		1: migrateVideoSliceToStream,
+		2: migrateCodecHeader,
+	}
+)
+
+/*
+Register migration of the payload from version `from` to `from+1`
+*/
+func RegisterMigration(from uint16, migration EntMigration) {
+	migrationsLock.Lock()
+	defer migrationsLock.Unlock()
+
+	migrations[from] = migration
+}
+
+/*
+Files written before the header existed have the same gob payload as version 1
+*/
+func migrateHeaderless(hdr *EntFileHeader, payload io.Reader) (io.Reader, error) {
+	hdr.Version = 1
+	return payload, nil
+}
+
+/*
+Re-encode the single []EntVideoForLoad of version 1 as a stream of records.
+The old slice has to be decoded whole, it is done once per legacy file.
+*/
+func migrateVideoSliceToStream(hdr *EntFileHeader, payload io.Reader) (io.Reader, error) {
+	hdr.Version = 2
+	if hdr.Kind != EntFileVideos {
+		return payload, nil
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		items := make([]EntVideoForLoad, 0)
+		if err := gob.NewDecoder(payload).Decode(&items); err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+
+		encoder := gob.NewEncoder(pw)
+		for pos := range items {
+			if err := encoder.Encode(&items[pos]); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+		pw.Close()
+	}()
+
+	return pr, nil
+}
+
+/*
+Payload of version 2 is never compressed, only the header layout changed
+*/
+func migrateCodecHeader(hdr *EntFileHeader, payload io.Reader) (io.Reader, error) {
+	hdr.Version = 3
+	return payload, nil
+}
+
+/*
+Write header in the layout of hdr.Version
+*/
+func WriteEntFileHeader(w io.Writer, hdr EntFileHeader) error {
+	prefix := entFileHeaderPrefix{Magic: EntFileMagic, Version: hdr.Version}
+	if err := binary.Write(w, binary.LittleEndian, &prefix); err != nil {
+		return err
+	}
+
+	if hdr.Version < 3 {
+		return binary.Write(w, binary.LittleEndian, &entFileHeaderV1{
+			Kind:      hdr.Kind,
+			Count:     hdr.Count,
+			CreatedAt: hdr.CreatedAt.UnixNano(),
+		})
+	}
+
+	return binary.Write(w, binary.LittleEndian, &entFileHeaderV3{
+		Kind:      hdr.Kind,
+		Codec:     hdr.Codec,
+		Count:     hdr.Count,
+		CreatedAt: hdr.CreatedAt.UnixNano(),
+	})
+}
+
+/*
+Read header from r, a file without the magic bytes is reported as version 0
+and nothing is consumed from r.
+*/
+func ReadEntFileHeader(r *bufio.Reader) (*EntFileHeader, error) {
+	magic, err := r.Peek(len(EntFileMagic))
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, err
+	}
+
+	if !bytes.Equal(magic, EntFileMagic[:]) {
+		return &EntFileHeader{Version: 0, Kind: EntFileUnknown}, nil
+	}
+
+	var prefix entFileHeaderPrefix
+	if err := binary.Read(r, binary.LittleEndian, &prefix); err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+
+	if prefix.Version < 3 {
+		var disk entFileHeaderV1
+		if err := binary.Read(r, binary.LittleEndian, &disk); err != nil {
+			return nil, fmt.Errorf("read header: %w", err)
+		}
+		return &EntFileHeader{
+			Version:   prefix.Version,
+			Kind:      disk.Kind,
+			Count:     disk.Count,
+			CreatedAt: time.Unix(0, disk.CreatedAt),
+		}, nil
+	}
+
+	var disk entFileHeaderV3
+	if err := binary.Read(r, binary.LittleEndian, &disk); err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	return &EntFileHeader{
+		Version:   prefix.Version,
+		Kind:      disk.Kind,
+		Codec:     disk.Codec,
+		Count:     disk.Count,
+		CreatedAt: time.Unix(0, disk.CreatedAt),
+	}, nil
+}
+
+/*
+Bring payload of an older version up to EntFormatVersion
+*/
+func MigrateEntFile(hdr *EntFileHeader, payload io.Reader) (io.Reader, error) {
+	if hdr.Version > EntFormatVersion {
+		return nil, fmt.Errorf("format version %d is newer than supported %d", hdr.Version, EntFormatVersion)
+	}
+
+	migrationsLock.RLock()
+	defer migrationsLock.RUnlock()
+
+	for hdr.Version < EntFormatVersion {
+		migration, exists := migrations[hdr.Version]
+		if !exists {
+			return nil, fmt.Errorf("no migration from format version %d", hdr.Version)
+		}
+
+		from := hdr.Version
+		upgraded, err := migration(hdr, payload)
+		if err != nil {
+			return nil, fmt.Errorf("migrate from format version %d: %w", from, err)
+		}
+		if hdr.Version != from+1 {
+			return nil, fmt.Errorf("migration from format version %d produced version %d", from, hdr.Version)
+		}
+		payload = upgraded
+	}
+
+	return payload, nil
+}
+
+/*
+Write header and gob payload of v to filepath atomically
+*/
+func EncodeEntFile(filepath string, kind EntFileKind, codec EntCodecId, count int, v interface{}) (FileChecksum, error) {
+	return encodeEntStream(filepath, kind, codec, count, func(encoder *gob.Encoder) error {
+		return encoder.Encode(v)
+	})
+}
+
+/*
+Write videos as a stream of records, each video is converted right before it is encoded
+*/
+func EncodeEntVideos(filepath string, codec EntCodecId, count int, video func(pos int) EntVideoForLoad) (FileChecksum, error) {
+	return encodeEntStream(filepath, EntFileVideos, codec, count, func(encoder *gob.Encoder) error {
+		for pos := 0; pos < count; pos++ {
+			evfl := video(pos)
+			if err := encoder.Encode(&evfl); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func encodeEntStream(filepath string, kind EntFileKind, codecId EntCodecId, count int, encode func(encoder *gob.Encoder) error) (FileChecksum, error) {
+	codec, err := GetCodec(codecId)
+	if err != nil {
+		return FileChecksum{}, err
+	}
+
+	return WriteFileAtomic(filepath, func(w io.Writer) error {
+		hdr := EntFileHeader{
+			Version:   EntFormatVersion,
+			Kind:      kind,
+			Codec:     codecId,
+			Count:     uint64(count),
+			CreatedAt: time.Now(),
+		}
+		if err := WriteEntFileHeader(w, hdr); err != nil {
+			return err
+		}
+
+		cw, err := codec.NewWriter(w)
+		if err != nil {
+			return err
+		}
+		if err := encode(gob.NewEncoder(cw)); err != nil {
+			cw.Close()
+			return err
+		}
+		return cw.Close()
+	})
+}
+
+/*
+Decode file written by EncodeEntFile into v, older versions are migrated first
+*/
+func DecodeEntFile(filepath string, kind EntFileKind, v interface{}) (*EntFileHeader, error) {
+	return decodeEntStream(filepath, kind, func(decoder *gob.Decoder) error {
+		if err := decoder.Decode(v); err != nil {
+			return corrupt("", err)
+		}
+		return nil
+	})
+}
+
+/*
+Decode videos one record at a time, fn is called before the next record is read.
+The number of records is checked against the header.
+*/
+func DecodeEntVideos(filepath string, fn func(evfl *EntVideoForLoad) error) (*EntFileHeader, error) {
+	var count uint64
+
+	hdr, err := decodeEntStream(filepath, EntFileVideos, func(decoder *gob.Decoder) error {
+		for {
+			evfl := &EntVideoForLoad{}
+			if err := decoder.Decode(evfl); err == io.EOF {
+				return nil
+			} else if err != nil {
+				return corrupt("", fmt.Errorf("record %d: %w", count, err))
+			}
+			count++
+
+			if err := fn(evfl); err != nil {
+				return err
+			}
+		}
+	})
+	if err != nil {
+		return hdr, err
+	}
+
+	if hdr.Count > 0 && hdr.Count != count {
+		return hdr, corrupt(filepath, fmt.Errorf("header has %d records, decoded %d", hdr.Count, count))
+	}
+
+	return hdr, nil
+}
+
+func decodeEntStream(filepath string, kind EntFileKind, decode func(decoder *gob.Decoder) error) (*EntFileHeader, error) {
+	f, err := os.Open(filepath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+
+	hdr, err := ReadEntFileHeader(r)
+	if err != nil {
+		return nil, corrupt(filepath, err)
+	}
+
+	// Headerless files do not record the kind, trust the caller
+	if hdr.Kind == EntFileUnknown {
+		hdr.Kind = kind
+	}
+	if hdr.Kind != kind {
+		return nil, corrupt(filepath, fmt.Errorf("file kind %d, wanted %d", hdr.Kind, kind))
+	}
+
+	if hdr.Version > EntFormatVersion {
+		return nil, fmt.Errorf("%s: format version %d is newer than supported %d", filepath, hdr.Version, EntFormatVersion)
+	}
+
+	codec, err := GetCodec(hdr.Codec)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath, err)
+	}
+
+	compressed, err := codec.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath, err)
+	}
+	defer compressed.Close()
+
+	payload, err := MigrateEntFile(hdr, compressed)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath, err)
+	}
+	if closer, ok := payload.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	if err := decode(gob.NewDecoder(payload)); err != nil {
+		return hdr, fmt.Errorf("%s: %w", filepath, err)
+	}
+
+	return hdr, nil
+}
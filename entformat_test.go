@@ -0,0 +1,214 @@
+package goentdb
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEntFileHeader(t *testing.T) {
+	var buf bytes.Buffer
+
+	Expected := EntFileHeader{
+		Version:   EntFormatVersion,
+		Kind:      EntFileVideos,
+		Count:     42,
+		CreatedAt: time.Unix(1700000000, 0),
+	}
+	if err := WriteEntFileHeader(&buf, Expected); err != nil {
+		t.Fatalf("test write header failed: %v", err)
+	}
+
+	Got, err := ReadEntFileHeader(bufio.NewReader(&buf))
+	if err != nil {
+		t.Fatalf("test read header failed: %v", err)
+	}
+	if Got.Version != Expected.Version || Got.Kind != Expected.Kind || Got.Count != Expected.Count || !Got.CreatedAt.Equal(Expected.CreatedAt) {
+		t.Errorf("test header round trip failed: got %v, wanted %v", Got, Expected)
+	}
+}
+
+func TestEntFileHeaderless(t *testing.T) {
+	entdb := NewEntDB(t.TempDir())
+
+	dict := map[int]*EntKeyword{}
+	for i := 0; i < 10; i++ {
+		dict[i] = NewTag(i, fmt.Sprintf("tag-%d", i))
+	}
+
+	// Files dumped before the header existed are plain gob
+	if err := EncodeToFilepath(entdb.GetDictTagsPath(), dict); err != nil {
+		t.Fatalf("test write legacy file failed: %v", err)
+	}
+
+	if err := entdb.LoadTags(); err != nil {
+		t.Errorf("test load legacy file failed: %v", err)
+	}
+
+	Expected := 10
+	Got := len(entdb.DictTags)
+	if Got != Expected {
+		t.Errorf("test load legacy tags count failed: got %v, wanted %v", Got, Expected)
+	}
+}
+
+func TestEntFileTooNew(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tags")
+
+	f, _ := os.Create(path)
+	WriteEntFileHeader(f, EntFileHeader{Version: EntFormatVersion + 1, Kind: EntFileKeywords})
+	gob.NewEncoder(f).Encode(map[int]*EntKeyword{})
+	f.Close()
+
+	dict := map[int]*EntKeyword{}
+	_, err := DecodeEntFile(path, EntFileKeywords, &dict)
+	if err == nil || !strings.Contains(err.Error(), "newer than supported") {
+		t.Errorf("test newer format should be refused: got %v", err)
+	}
+}
+
+func TestEntFileWrongKind(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "videos")
+
+	if _, err := EncodeEntFile(path, EntFileVideos, CodecNone, 0, []EntVideoForLoad{}); err != nil {
+		t.Fatalf("test encode failed: %v", err)
+	}
+
+	dict := map[int]*EntKeyword{}
+	if _, err := DecodeEntFile(path, EntFileKeywords, &dict); err == nil {
+		t.Errorf("test decode of wrong kind should fail")
+	}
+}
+
+func TestEntFileCountMismatch(t *testing.T) {
+	entdb := NewEntDB(t.TempDir())
+
+	dict := map[int]*EntKeyword{1: NewTag(1, "tag-1")}
+	if _, err := EncodeEntFile(entdb.GetDictTagsPath(), EntFileKeywords, CodecNone, 2, dict); err != nil {
+		t.Fatalf("test encode failed: %v", err)
+	}
+
+	if err := entdb.LoadTags(); err == nil {
+		t.Errorf("test load with count mismatch should fail")
+	}
+}
+
+func TestEntFileMigration(t *testing.T) {
+	defer RegisterMigration(0, migrateHeaderless)
+
+	migrated := false
+	RegisterMigration(0, func(hdr *EntFileHeader, payload io.Reader) (io.Reader, error) {
+		migrated = true
+		hdr.Version = 1
+		return payload, nil
+	})
+
+	hdr := &EntFileHeader{Version: 0}
+	if _, err := MigrateEntFile(hdr, bytes.NewReader(nil)); err != nil {
+		t.Errorf("test migration failed: %v", err)
+	}
+	if !migrated || hdr.Version != EntFormatVersion {
+		t.Errorf("test migration failed: got version %v, wanted %v", hdr.Version, EntFormatVersion)
+	}
+
+	RegisterMigration(0, func(hdr *EntFileHeader, payload io.Reader) (io.Reader, error) {
+		return payload, nil
+	})
+	if _, err := MigrateEntFile(&EntFileHeader{Version: 0}, bytes.NewReader(nil)); err == nil {
+		t.Errorf("test migration without version bump should fail")
+	}
+}
+
+func GenerateVideosForLoad(n int) []EntVideoForLoad {
+	items := make([]EntVideoForLoad, n)
+	for i := range items {
+		items[i] = EntVideoForLoad{
+			Id:    uint(i + 1),
+			Title: fmt.Sprintf("title number %d", i+1),
+			Slug:  fmt.Sprintf("title-number-%d", i+1),
+		}
+	}
+	return items
+}
+
+func TestEntFileVideoStream(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "videos")
+	items := GenerateVideosForLoad(100)
+
+	_, err := EncodeEntVideos(path, CodecNone, len(items), func(pos int) EntVideoForLoad {
+		return items[pos]
+	})
+	if err != nil {
+		t.Fatalf("test encode video stream failed: %v", err)
+	}
+
+	Got := make([]uint, 0)
+	hdr, err := DecodeEntVideos(path, func(evfl *EntVideoForLoad) error {
+		Got = append(Got, evfl.Id)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("test decode video stream failed: %v", err)
+	}
+
+	if hdr.Count != uint64(len(items)) || len(Got) != len(items) {
+		t.Fatalf("test video stream count failed: got %v/%v, wanted %v", hdr.Count, len(Got), len(items))
+	}
+	for pos, id := range Got {
+		if id != items[pos].Id {
+			t.Errorf("test video stream order failed: got %v, wanted %v", id, items[pos].Id)
+		}
+	}
+
+	stop := errors.New("stop")
+	decoded := 0
+	_, err = DecodeEntVideos(path, func(evfl *EntVideoForLoad) error {
+		decoded++
+		return stop
+	})
+	if !errors.Is(err, stop) || decoded != 1 {
+		t.Errorf("test video stream stop failed: got %v after %v records", err, decoded)
+	}
+}
+
+func TestEntFileVideoSliceMigration(t *testing.T) {
+	entdb := NewEntDB(t.TempDir())
+	items := GenerateVideosForLoad(10)
+
+	// Version 1 stored videos as one slice
+	f, _ := os.Create(entdb.GetDictVideosPath())
+	WriteEntFileHeader(f, EntFileHeader{Version: 1, Kind: EntFileVideos, Count: uint64(len(items))})
+	gob.NewEncoder(f).Encode(items)
+	f.Close()
+
+	if err := entdb.LoadVideos(); err != nil {
+		t.Errorf("test load version 1 videos failed: %v", err)
+	}
+
+	Expected := 10
+	Got := len(entdb.Items)
+	if Got != Expected {
+		t.Errorf("test load version 1 videos count failed: got %v, wanted %v", Got, Expected)
+	}
+
+	// Version 0 is the same slice without header
+	entdb = NewEntDB(t.TempDir())
+	EncodeToFilepath(entdb.GetDictVideosPath(), items)
+
+	if err := entdb.LoadVideos(); err != nil {
+		t.Errorf("test load headerless videos failed: %v", err)
+	}
+
+	Got = len(entdb.Items)
+	if Got != Expected {
+		t.Errorf("test load headerless videos count failed: got %v, wanted %v", Got, Expected)
+	}
+}
@@ -0,0 +1,254 @@
+package goentdb
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+/*
+Result of a typo-tolerant search, see EntDB.SearchFuzzy
+*/
+type EntSearchResponse struct {
+	Results    []EntSearchResult
+	Total      int
+	DidYouMean string // Corrected query, set when nothing matched the query as typed
+}
+
+type trieMatch struct {
+	key      string
+	term     *EntSuggestion
+	distance int
+}
+
+/*
+Edit distance allowed for a word: none for short words, where one typo
+makes another word, 1 up to 7 characters and 2 for longer ones
+*/
+func fuzzyDistance(word string) int {
+	switch length := utf8.RuneCountInString(word); {
+	case length < 4:
+		return 0
+	case length < 8:
+		return 1
+	}
+	return 2
+}
+
+/*
+Terms within maxDistance of key, closest first, then the most used.
+The distance counts inserted, deleted and replaced characters and swapped
+neighbours (optimal string alignment). Subtrees are skipped once every
+prefix of key is further away than maxDistance.
+*/
+func (t *entTrie) fuzzy(key string, maxDistance int) []trieMatch {
+	res := make([]trieMatch, 0)
+	if t.root == nil {
+		return res
+	}
+
+	chars := []rune(key)
+	row := make([]int, len(chars)+1)
+	for i := range row {
+		row[i] = i
+	}
+
+	// Trie labels are bytes, a row is added once the bytes of a whole
+	// character are walked. Characters of prefix from start on are incomplete.
+	var walk func(node *trieNode, prefix string, start int, last rune, before []int, row []int)
+	walk = func(node *trieNode, prefix string, start int, last rune, before []int, row []int) {
+		if node.term != nil && row[len(chars)] <= maxDistance {
+			res = append(res, trieMatch{key: prefix, term: node.term, distance: row[len(chars)]})
+		}
+
+		for pos, child := range node.children {
+			path := prefix + string(node.labels[pos:pos+1])
+			if !utf8.FullRuneInString(path[start:]) {
+				walk(child, path, start, last, before, row)
+				continue
+			}
+
+			char, _ := utf8.DecodeRuneInString(path[start:])
+			next := make([]int, len(chars)+1)
+			next[0] = row[0] + 1
+
+			for i := 1; i <= len(chars); i++ {
+				cost := 1
+				if chars[i-1] == char {
+					cost = 0
+				}
+				next[i] = Min(Min(row[i]+1, next[i-1]+1), row[i-1]+cost)
+
+				if before != nil && i > 1 && chars[i-1] == last && chars[i-2] == char {
+					next[i] = Min(next[i], before[i-2]+1)
+				}
+			}
+
+			// A swap reaches back one more row
+			if minInts(next) <= maxDistance || minInts(row)+1 <= maxDistance {
+				walk(child, path, len(path), char, row, next)
+			}
+		}
+	}
+	walk(t.root, "", 0, 0, nil, row)
+
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].distance != res[j].distance {
+			return res[i].distance < res[j].distance
+		}
+		if res[i].term.Count != res[j].term.Count {
+			return res[i].term.Count > res[j].term.Count
+		}
+		return res[i].key < res[j].key
+	})
+
+	return res
+}
+
+func minInts(values []int) int {
+	res := values[0]
+	for _, value := range values[1:] {
+		res = Min(res, value)
+	}
+	return res
+}
+
+func fuzzySuggestions(matches []trieMatch, Size int) []EntSuggestion {
+	res := make([]EntSuggestion, 0, Min(len(matches), Size))
+	for _, match := range matches[:Min(len(matches), Size)] {
+		res = append(res, *match.term)
+	}
+	return res
+}
+
+/*
+Tags with a phrase within the edit distance allowed for phrase, closest and most used first
+*/
+func (idx *EntIndex) FuzzyTags(phrase string, Size int) []EntSuggestion {
+	key := suggestKey(phrase)
+	return fuzzySuggestions(idx.tagTrie.fuzzy(key, fuzzyDistance(key)), Size)
+}
+
+/*
+Models with a phrase within the edit distance allowed for phrase, closest and most used first
+*/
+func (idx *EntIndex) FuzzyModels(phrase string, Size int) []EntSuggestion {
+	key := suggestKey(phrase)
+	return fuzzySuggestions(idx.modelTrie.fuzzy(key, fuzzyDistance(key)), Size)
+}
+
+/*
+Title tokens within the edit distance allowed for word, closest and most used first
+*/
+func (idx *EntIndex) FuzzyTokens(word string, Size int) []EntSuggestion {
+	key := suggestKey(word)
+	return fuzzySuggestions(idx.tokenTrie.fuzzy(key, fuzzyDistance(key)), Size)
+}
+
+/*
+Closest title word for every word of the query which is in no title.
+Words are matched as they are typed, query.Words are stems.
+*/
+func (idx *EntIndex) corrections(query *EntSearchQuery) map[string]string {
+	analyzer := idx.Analyzer()
+	res := make(map[string]string)
+
+	for _, run := range query.runs {
+		for _, word := range run {
+			if !isSearchToken(word) || analyzer.StopWords[word] || len(idx.BySearch(analyzer.Stem(word))) > 0 {
+				continue
+			}
+			if matches := idx.tokenTrie.fuzzy(word, fuzzyDistance(word)); len(matches) > 0 {
+				res[word] = matches[0].key
+			}
+		}
+	}
+
+	return res
+}
+
+/*
+Replace the corrected words in Query, everything else is kept as typed
+*/
+func correctQuery(Query string, corrections map[string]string) string {
+	var res strings.Builder
+
+	isSeparator := func(char byte) bool {
+		return char == ' ' || char == '-' || char == '"'
+	}
+
+	for pos := 0; pos < len(Query); {
+		if isSeparator(Query[pos]) {
+			res.WriteByte(Query[pos])
+			pos++
+			continue
+		}
+
+		end := pos
+		for end < len(Query) && !isSeparator(Query[end]) {
+			end++
+		}
+
+		segment := Query[pos:end]
+		word := strings.Trim(segment, TrimSymbols)
+		if correction, exists := corrections[foldText(word)]; exists && word != "" {
+			segment = strings.Replace(segment, word, correction, 1)
+		}
+		res.WriteString(segment)
+
+		pos = end
+	}
+
+	return res.String()
+}
+
+/*
+Typo-tolerant SearchRanked.
+Words which are in no title are searched as their closest title tokens too.
+When nothing matches Query as typed, DidYouMean holds the model or tag phrase
+closest to the whole query and the results are its videos, otherwise it holds
+the query with those words corrected.
+*/
+func (edb *EntDB) SearchFuzzy(Query string, Size int) *EntSearchResponse {
+	idx := edb.Index()
+	analyzer := idx.Analyzer()
+	query := analyzer.ParseSearchQuery(Query)
+
+	results := idx.Search(query, edb.Ranking)
+	exact := len(results) > 0
+	didYouMean := ""
+
+	if corrections := idx.corrections(query); len(corrections) > 0 {
+		corrected := analyzer.ParseSearchQuery(correctQuery(Query, corrections))
+		corrected.Words = append(corrected.Words, query.Words...)
+		results = idx.Search(corrected, edb.Ranking)
+		didYouMean = correctQuery(Query, corrections)
+	}
+
+	// A misspelled model or tag name is a better guess than its corrected words
+	if !exact {
+		if videos, phrase := idx.keywordCorrection(Query); len(videos) > 0 {
+			results, didYouMean = videos, phrase
+		}
+	}
+
+	res := &EntSearchResponse{Results: results[:Min(len(results), Size)], Total: len(results)}
+	if !exact && len(results) > 0 {
+		res.DidYouMean = didYouMean
+	}
+
+	return res
+}
+
+/*
+Videos of the model or tag closest to the whole query and its phrase
+*/
+func (idx *EntIndex) keywordCorrection(Query string) ([]EntSearchResult, string) {
+	if models := idx.FuzzyModels(Query, 1); len(models) > 0 && models[0].Count > 0 {
+		return videoResults(distinctVideos(idx.ByModel(models[0].Keyword.GetSlug()))), models[0].Phrase
+	}
+	if tags := idx.FuzzyTags(Query, 1); len(tags) > 0 && tags[0].Count > 0 {
+		return videoResults(distinctVideos(idx.ByTag(tags[0].Keyword.GetSlug()))), tags[0].Phrase
+	}
+	return make([]EntSearchResult, 0), ""
+}
@@ -0,0 +1,87 @@
+package goentdb
+
+import (
+	"testing"
+)
+
+func TestEntTrieFuzzy(t *testing.T) {
+	trie := newTrie()
+	for count, key := range []string{"blonde", "blond", "blonder", "brunette", "bronde", "блондинка"} {
+		trie.set(key, &EntSuggestion{Phrase: key, Count: count})
+	}
+
+	cases := []struct {
+		Key         string
+		MaxDistance int
+		Expected    []string
+	}{
+		{Key: "blonde", MaxDistance: 0, Expected: []string{"blonde"}},
+		{Key: "blnode", MaxDistance: 1, Expected: []string{"blonde"}},
+		{Key: "blnde", MaxDistance: 1, Expected: []string{"blonde"}},
+		{Key: "blonde", MaxDistance: 1, Expected: []string{"blonde", "bronde", "blonder", "blond"}},
+		{Key: "brunete", MaxDistance: 1, Expected: []string{"brunette"}},
+		{Key: "brnuete", MaxDistance: 1, Expected: []string{}},
+		{Key: "brnuete", MaxDistance: 2, Expected: []string{"brunette"}},
+		// Distances count characters, not bytes
+		{Key: "блондинко", MaxDistance: 1, Expected: []string{"блондинка"}},
+		{Key: "блониднка", MaxDistance: 1, Expected: []string{"блондинка"}},
+		{Key: "blondé", MaxDistance: 1, Expected: []string{"blond", "blonde"}},
+	}
+
+	for _, c := range []struct {
+		Word     string
+		Expected int
+	}{{"кот", 0}, {"кошка", 1}, {"блондинка", 2}, {"été", 0}} {
+		if Got := fuzzyDistance(c.Word); Got != c.Expected {
+			t.Errorf("test fuzzy distance %q failed: got %v, wanted %v", c.Word, Got, c.Expected)
+		}
+	}
+
+	for _, c := range cases {
+		Got := trie.fuzzy(c.Key, c.MaxDistance)
+		if len(Got) != len(c.Expected) {
+			t.Errorf("test trie fuzzy %q failed: got %v, wanted %v", c.Key, Got, c.Expected)
+			continue
+		}
+		for i, match := range Got {
+			if match.key != c.Expected[i] {
+				t.Errorf("test trie fuzzy %q failed: got %v, wanted %v", c.Key, match.key, c.Expected[i])
+			}
+		}
+	}
+}
+
+func TestEntDBSearchFuzzy(t *testing.T) {
+	entdb := GenerateQueryEntDB(t.TempDir())
+
+	res := entdb.SearchFuzzy("blonde beach", 10)
+	if res.Total != 2 || res.DidYouMean != "" {
+		t.Errorf("test search fuzzy exact failed: got %v %q, wanted %v %q", res.Total, res.DidYouMean, 2, "")
+	}
+
+	res = entdb.SearchFuzzy("Blnode on the \"beahc\"", 10)
+	Expected := "blonde on the \"beach\""
+	if res.Total != 1 || res.DidYouMean != Expected {
+		t.Errorf("test search fuzzy correction failed: got %v %q, wanted %v %q", res.Total, res.DidYouMean, 1, Expected)
+	}
+
+	// Misspelled words are searched as their corrections alongside exact matches
+	res = entdb.SearchFuzzy("blonde exsct", 10)
+	if res.Total != 4 || res.DidYouMean != "" {
+		t.Errorf("test search fuzzy expansion failed: got %v %q, wanted %v %q", res.Total, res.DidYouMean, 4, "")
+	}
+
+	res = entdb.SearchFuzzy("jnae doe", 10)
+	if res.Total != 3 || res.DidYouMean != "Jane Doe" {
+		t.Errorf("test search fuzzy model failed: got %v %q, wanted %v %q", res.Total, res.DidYouMean, 3, "Jane Doe")
+	}
+
+	res = entdb.SearchFuzzy("qwerty", 10)
+	if res.Total != 0 || res.DidYouMean != "" {
+		t.Errorf("test search fuzzy without correction failed: got %v %q", res.Total, res.DidYouMean)
+	}
+
+	if Got := entdb.Index().FuzzyTags("outdor", 5); len(Got) != 1 || Got[0].Phrase != "outdoor" {
+		t.Errorf("test fuzzy tags failed: got %v", Got)
+	}
+}
@@ -0,0 +1,539 @@
+package goentdb
+
+import (
+	"math/bits"
+	"math/rand"
+)
+
+/*
+Persistent hash array mapped trie.
+Nodes are never changed once a generation is published, the writer copies
+the path to a key on its first change after a publish and keeps changing
+the copies in place until the next publish.
+*/
+type entHAMT[K comparable, V any] struct {
+	root *hamtNode[K, V]
+	size int
+	edit uint64 // Writer only: nodes stamped with it are not shared yet
+	hash func(K) uint32
+}
+
+const (
+	hamtBits  = 5
+	hamtMask  = 1<<hamtBits - 1
+	hamtDepth = 32 // Past this shift the hash is used up, keys are kept in a list
+)
+
+type hamtNode[K comparable, V any] struct {
+	bitmap  uint32
+	entries []hamtEntry[K, V]
+	edit    uint64
+}
+
+/*
+Either a subtree or a leaf
+*/
+type hamtEntry[K comparable, V any] struct {
+	node *hamtNode[K, V]
+	leaf *hamtLeaf[K, V]
+}
+
+type hamtLeaf[K comparable, V any] struct {
+	hash  uint32
+	key   K
+	value V
+	edit  uint64
+}
+
+func newHAMT[K comparable, V any](hash func(K) uint32) entHAMT[K, V] {
+	return entHAMT[K, V]{hash: hash, edit: 1}
+}
+
+func (m *entHAMT[K, V]) get(key K) (V, bool) {
+	if leaf := m.find(key); leaf != nil {
+		return leaf.value, true
+	}
+
+	var zero V
+	return zero, false
+}
+
+func (m *entHAMT[K, V]) find(key K) *hamtLeaf[K, V] {
+	hash := m.hash(key)
+	node := m.root
+
+	for shift := uint(0); node != nil; shift += hamtBits {
+		if shift >= hamtDepth {
+			for _, entry := range node.entries {
+				if entry.leaf.key == key {
+					return entry.leaf
+				}
+			}
+			return nil
+		}
+
+		bit := uint32(1) << ((hash >> shift) & hamtMask)
+		if node.bitmap&bit == 0 {
+			return nil
+		}
+
+		entry := node.entries[bits.OnesCount32(node.bitmap&(bit-1))]
+		if entry.node != nil {
+			node = entry.node
+			continue
+		}
+		if entry.leaf.key == key {
+			return entry.leaf
+		}
+		return nil
+	}
+
+	return nil
+}
+
+func (m *entHAMT[K, V]) len() int {
+	return m.size
+}
+
+func (m *entHAMT[K, V]) each(fn func(key K, value V)) {
+	var walk func(node *hamtNode[K, V])
+	walk = func(node *hamtNode[K, V]) {
+		if node == nil {
+			return
+		}
+		for _, entry := range node.entries {
+			if entry.node != nil {
+				walk(entry.node)
+			} else {
+				fn(entry.leaf.key, entry.leaf.value)
+			}
+		}
+	}
+	walk(m.root)
+}
+
+func (m *entHAMT[K, V]) editable(node *hamtNode[K, V]) *hamtNode[K, V] {
+	if node == nil {
+		return &hamtNode[K, V]{edit: m.edit}
+	}
+	if node.edit == m.edit {
+		return node
+	}
+
+	entries := make([]hamtEntry[K, V], len(node.entries), len(node.entries)+1)
+	copy(entries, node.entries)
+
+	return &hamtNode[K, V]{bitmap: node.bitmap, entries: entries, edit: m.edit}
+}
+
+func (m *entHAMT[K, V]) set(key K, value V) {
+	// Leaf created since the last publish sits on a path the writer owns
+	if leaf := m.find(key); leaf != nil && leaf.edit == m.edit {
+		leaf.value = value
+		return
+	}
+
+	leaf := &hamtLeaf[K, V]{hash: m.hash(key), key: key, value: value, edit: m.edit}
+
+	root, added := m.insert(m.root, 0, leaf)
+	m.root = root
+	if added {
+		m.size++
+	}
+}
+
+func (m *entHAMT[K, V]) insert(node *hamtNode[K, V], shift uint, leaf *hamtLeaf[K, V]) (*hamtNode[K, V], bool) {
+	node = m.editable(node)
+
+	if shift >= hamtDepth {
+		for pos, entry := range node.entries {
+			if entry.leaf.key == leaf.key {
+				node.entries[pos].leaf = leaf
+				return node, false
+			}
+		}
+		node.entries = append(node.entries, hamtEntry[K, V]{leaf: leaf})
+		return node, true
+	}
+
+	bit := uint32(1) << ((leaf.hash >> shift) & hamtMask)
+	pos := bits.OnesCount32(node.bitmap & (bit - 1))
+
+	if node.bitmap&bit == 0 {
+		node.entries = append(node.entries, hamtEntry[K, V]{})
+		copy(node.entries[pos+1:], node.entries[pos:])
+		node.entries[pos] = hamtEntry[K, V]{leaf: leaf}
+		node.bitmap |= bit
+		return node, true
+	}
+
+	entry := node.entries[pos]
+	if entry.node != nil {
+		child, added := m.insert(entry.node, shift+hamtBits, leaf)
+		node.entries[pos].node = child
+		return node, added
+	}
+
+	if entry.leaf.key == leaf.key {
+		node.entries[pos].leaf = leaf
+		return node, false
+	}
+
+	// Two keys share the prefix of the hash, push both one level down
+	child, _ := m.insert(nil, shift+hamtBits, entry.leaf)
+	child, _ = m.insert(child, shift+hamtBits, leaf)
+	node.entries[pos] = hamtEntry[K, V]{node: child}
+
+	return node, true
+}
+
+func (m *entHAMT[K, V]) delete(key K) {
+	root, removed := m.remove(m.root, 0, m.hash(key), key)
+	m.root = root
+	if removed {
+		m.size--
+	}
+}
+
+func (m *entHAMT[K, V]) remove(node *hamtNode[K, V], shift uint, hash uint32, key K) (*hamtNode[K, V], bool) {
+	if node == nil {
+		return nil, false
+	}
+
+	if shift >= hamtDepth {
+		for pos, entry := range node.entries {
+			if entry.leaf.key == key {
+				return m.removeEntry(node, pos, 0), true
+			}
+		}
+		return node, false
+	}
+
+	bit := uint32(1) << ((hash >> shift) & hamtMask)
+	if node.bitmap&bit == 0 {
+		return node, false
+	}
+	pos := bits.OnesCount32(node.bitmap & (bit - 1))
+
+	entry := node.entries[pos]
+	if entry.node == nil {
+		if entry.leaf.key != key {
+			return node, false
+		}
+		return m.removeEntry(node, pos, bit), true
+	}
+
+	child, removed := m.remove(entry.node, shift+hamtBits, hash, key)
+	if !removed {
+		return node, false
+	}
+	if child == nil {
+		return m.removeEntry(node, pos, bit), true
+	}
+
+	node = m.editable(node)
+	if len(child.entries) == 1 && child.entries[0].leaf != nil {
+		// A subtree with a single leaf is folded back into its parent
+		node.entries[pos] = child.entries[0]
+	} else {
+		node.entries[pos].node = child
+	}
+
+	return node, true
+}
+
+func (m *entHAMT[K, V]) removeEntry(node *hamtNode[K, V], pos int, bit uint32) *hamtNode[K, V] {
+	if len(node.entries) == 1 {
+		return nil
+	}
+
+	node = m.editable(node)
+	node.entries = append(node.entries[:pos], node.entries[pos+1:]...)
+	node.bitmap &^= bit
+
+	return node
+}
+
+/*
+Copy the value of key from the writer map, or drop it when it is gone there
+*/
+func (m *entHAMT[K, V]) sync(master map[K]V, key K) {
+	if value, exists := master[key]; exists {
+		m.set(key, value)
+		return
+	}
+	m.delete(key)
+}
+
+/*
+Called on the writer copy once it is published, every node is shared from now on
+*/
+func (m *entHAMT[K, V]) freeze() {
+	m.edit++
+}
+
+func hashString(key string) uint32 {
+	// FNV-1a
+	hash := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		hash ^= uint32(key[i])
+		hash *= 16777619
+	}
+	return hash
+}
+
+func hashInt(key int) uint32 {
+	return uint32(key) * 2654435761
+}
+
+func hashUint(key uint) uint32 {
+	return uint32(key) * 2654435761
+}
+
+/*
+Immutable generation of the EntDB indexes.
+Readers get it through EntDB.Index() without taking any lock, writers build
+the next generation under the EntDB lock and publish it when they are done.
+Slices returned by the methods are shared and must not be modified.
+*/
+type EntIndex struct {
+	Generation uint64
+	Items      []*EntVideo
+	origins    map[Origin]int
+	tags       entHAMT[string, []*EntVideo]
+	models     entHAMT[string, []*EntVideo]
+	search     entHAMT[string, []*EntVideo]
+	keywords   entHAMT[string, *EntVideo]
+	dictTags   entHAMT[int, *EntKeyword]
+	dictModels entHAMT[int, *EntKeyword]
+	dictVideos entHAMT[uint, *EntVideo]
+	twoGrams   entHAMT[string, []*EntVideo]
+	threeGrams entHAMT[string, []*EntVideo]
+	tagTrie    entTrie // Suggestions of DictTags by lowercase phrase
+	modelTrie  entTrie // Suggestions of DictModels by lowercase phrase
+	tokenTrie  entTrie // Suggestions of the title words of the Search terms
+	language   EntLanguage
+	synonyms   entSynonyms
+	redirects  entRedirects
+
+	fieldTokens [searchFields]int // Tokens of each text search field over all videos
+	fieldStats  entHAMT[uint, *entFieldStats]
+}
+
+func newEntIndex() *EntIndex {
+	return &EntIndex{
+		Items:      make([]*EntVideo, 0),
+		origins:    make(map[Origin]int),
+		tags:       newHAMT[string, []*EntVideo](hashString),
+		models:     newHAMT[string, []*EntVideo](hashString),
+		search:     newHAMT[string, []*EntVideo](hashString),
+		keywords:   newHAMT[string, *EntVideo](hashString),
+		dictTags:   newHAMT[int, *EntKeyword](hashInt),
+		dictModels: newHAMT[int, *EntKeyword](hashInt),
+		dictVideos: newHAMT[uint, *EntVideo](hashUint),
+		twoGrams:   newHAMT[string, []*EntVideo](hashString),
+		threeGrams: newHAMT[string, []*EntVideo](hashString),
+		tagTrie:    newTrie(),
+		modelTrie:  newTrie(),
+		tokenTrie:  newTrie(),
+		fieldStats: newHAMT[uint, *entFieldStats](hashUint),
+	}
+}
+
+func (idx *EntIndex) Len() int {
+	return len(idx.Items)
+}
+
+func (idx *EntIndex) Video(id uint) (*EntVideo, bool) {
+	return idx.dictVideos.get(id)
+}
+
+/*
+Video by original slug md5 or keyword slug md5
+*/
+func (idx *EntIndex) VideoByMD5(key string) (*EntVideo, bool) {
+	return idx.keywords.get(key)
+}
+
+func (idx *EntIndex) Tag(id int) (*EntKeyword, bool) {
+	return idx.dictTags.get(id)
+}
+
+func (idx *EntIndex) Model(id int) (*EntKeyword, bool) {
+	return idx.dictModels.get(id)
+}
+
+func (idx *EntIndex) ByTag(slug string) []*EntVideo {
+	videos, _ := idx.tags.get(slug)
+	return videos
+}
+
+func (idx *EntIndex) ByModel(slug string) []*EntVideo {
+	videos, _ := idx.models.get(slug)
+	return videos
+}
+
+func (idx *EntIndex) BySearch(token string) []*EntVideo {
+	videos, _ := idx.search.get(token)
+	return videos
+}
+
+func (idx *EntIndex) ByTwoGram(gram string) []*EntVideo {
+	videos, _ := idx.twoGrams.get(gram)
+	return videos
+}
+
+func (idx *EntIndex) ByThreeGram(gram string) []*EntVideo {
+	videos, _ := idx.threeGrams.get(gram)
+	return videos
+}
+
+func (idx *EntIndex) Origin(origin Origin) int {
+	return idx.origins[origin]
+}
+
+func (idx *EntIndex) EachTag(fn func(tag *EntKeyword)) {
+	idx.dictTags.each(func(_ int, tag *EntKeyword) { fn(tag) })
+}
+
+func (idx *EntIndex) EachModel(fn func(model *EntKeyword)) {
+	idx.dictModels.each(func(_ int, model *EntKeyword) { fn(model) })
+}
+
+/*
+Random video, nil when the index is empty
+*/
+func (idx *EntIndex) Random() *EntVideo {
+	if len(idx.Items) == 0 {
+		return nil
+	}
+	return idx.Items[rand.Intn(len(idx.Items))]
+}
+
+func keywordMap(dict *entHAMT[int, *EntKeyword]) map[int]*EntKeyword {
+	res := make(map[int]*EntKeyword, dict.len())
+	dict.each(func(id int, keyword *EntKeyword) {
+		res[id] = keyword
+	})
+	return res
+}
+
+/*
+Copy every index entry of video from the writer maps, called after add and remove.
+indexVideo, indexTag, indexModel and publish are called with the lock held.
+*/
+func (edb *EntDB) indexVideo(video *EntVideo) {
+	next := edb.next
+
+	next.Items = edb.Items
+	for _, tag := range video.Tags {
+		slug := edb.synonyms.tags.slug(tag.GetSlug())
+		for _, member := range edb.synonyms.tags.group(slug) {
+			next.tags.sync(edb.Tags, member)
+		}
+		edb.suggestTag(tag.Id)
+		edb.synonyms.tags.suggest(&next.tagTrie, edb.Tags, slug)
+	}
+	for _, model := range video.Models {
+		slug := edb.synonyms.models.slug(model.GetSlug())
+		for _, member := range edb.synonyms.models.group(slug) {
+			next.models.sync(edb.Models, member)
+		}
+		edb.suggestModel(model.Id)
+		edb.synonyms.models.suggest(&next.modelTrie, edb.Models, slug)
+	}
+
+	next.keywords.sync(edb.Keywords, video.GetMD5())
+	for _, keyword := range video.Keywords {
+		next.keywords.sync(edb.Keywords, keyword.GetMD5())
+	}
+
+	next.dictVideos.sync(edb.DictVideos, video.Id)
+
+	if next.origins[video.Origin] != edb.Origins[video.Origin] {
+		origins := make(map[Origin]int, len(edb.Origins))
+		for origin, count := range edb.Origins {
+			origins[origin] = count
+		}
+		next.origins = origins
+	}
+
+	for _, token := range video.GetSearchTokens() {
+		next.search.sync(edb.Search, token)
+	}
+
+	TwoGrams, ThreeGrams := video.GetNGrams(false)
+	for _, gram := range TwoGrams {
+		next.twoGrams.sync(edb.TwoGrams, gram)
+	}
+	for _, gram := range ThreeGrams {
+		next.threeGrams.sync(edb.ThreeGrams, gram)
+	}
+
+	edb.dirty = true
+}
+
+/*
+A slug in use again is no longer redirected
+*/
+func (edb *EntDB) indexTag(id int) {
+	edb.keywordEdits++
+	edb.next.dictTags.sync(edb.DictTags, id)
+	edb.suggestTag(id)
+	if tag, exists := edb.DictTags[id]; exists {
+		edb.redirects.Tags = dropRedirect(edb.redirects.Tags, tag.GetSlug())
+	}
+	edb.dirty = true
+}
+
+func (edb *EntDB) indexModel(id int) {
+	edb.keywordEdits++
+	edb.next.dictModels.sync(edb.DictModels, id)
+	edb.suggestModel(id)
+	if model, exists := edb.DictModels[id]; exists {
+		edb.redirects.Models = dropRedirect(edb.redirects.Models, model.GetSlug())
+	}
+	edb.dirty = true
+}
+
+/*
+Make the generation built so far visible to readers.
+Bulk paths (Load, WAL replay, imports) publish once at the end.
+*/
+func (edb *EntDB) publish() {
+	if !edb.dirty {
+		return
+	}
+
+	next := edb.next
+	next.Generation++
+	next.language = edb.Language
+	next.synonyms = edb.synonyms
+	next.redirects = edb.redirects
+
+	published := *next
+	edb.index.Store(&published)
+
+	next.tags.freeze()
+	next.models.freeze()
+	next.search.freeze()
+	next.keywords.freeze()
+	next.dictTags.freeze()
+	next.dictModels.freeze()
+	next.dictVideos.freeze()
+	next.twoGrams.freeze()
+	next.threeGrams.freeze()
+	next.tagTrie.freeze()
+	next.modelTrie.freeze()
+	next.tokenTrie.freeze()
+	next.fieldStats.freeze()
+
+	edb.dirty = false
+}
+
+/*
+Last published generation, readers never block on writers
+*/
+func (edb *EntDB) Index() *EntIndex {
+	return edb.index.Load().(*EntIndex)
+}
@@ -0,0 +1,140 @@
+package goentdb
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestEntHAMT(t *testing.T) {
+	// Few hash bits force collisions and deep paths
+	for _, hash := range []func(int) uint32{hashInt, func(key int) uint32 { return uint32(key % 7) }} {
+		m := newHAMT[int, int](hash)
+		master := make(map[int]int)
+
+		var frozen []entHAMT[int, int]
+		var frozenMasters []map[int]int
+
+		for i := 0; i < 5000; i++ {
+			key := rand.Intn(500)
+			if rand.Intn(3) == 0 {
+				delete(master, key)
+				m.delete(key)
+			} else {
+				master[key] = i
+				m.set(key, i)
+			}
+
+			if i%500 == 0 {
+				copied := make(map[int]int, len(master))
+				for k, v := range master {
+					copied[k] = v
+				}
+				frozen = append(frozen, m)
+				frozenMasters = append(frozenMasters, copied)
+				m.freeze()
+			}
+		}
+
+		frozen = append(frozen, m)
+		frozenMasters = append(frozenMasters, master)
+
+		// Every published version still holds exactly what it held when it was published
+		for pos, version := range frozen {
+			if version.len() != len(frozenMasters[pos]) {
+				t.Errorf("test hamt len failed: got %v, wanted %v", version.len(), len(frozenMasters[pos]))
+			}
+			for key := 0; key < 500; key++ {
+				Expected, exists := frozenMasters[pos][key]
+				Got, found := version.get(key)
+				if found != exists || Got != Expected {
+					t.Errorf("test hamt get %d failed: got %v %v, wanted %v %v", key, Got, found, Expected, exists)
+				}
+			}
+			count := 0
+			version.each(func(key int, value int) { count++ })
+			if count != len(frozenMasters[pos]) {
+				t.Errorf("test hamt each failed: got %v, wanted %v", count, len(frozenMasters[pos]))
+			}
+		}
+	}
+}
+
+func TestEntIndexGeneration(t *testing.T) {
+	entdb := NewEntDB(t.TempDir())
+	entdb.AddTag(NewTag(1, "tag 1"))
+
+	newVideo := func(id int) *EntVideo {
+		video := NewEntVideo(entdb)
+		video.Id = uint(id)
+		video.Title = fmt.Sprintf("title number %d", id)
+		video.Slug = fmt.Sprintf("title-number-%d", id)
+		video.AddTag(entdb.DictTags[1])
+		return video
+	}
+
+	entdb.Add(newVideo(1))
+	entdb.Add(newVideo(2))
+
+	before := entdb.Index()
+
+	entdb.Add(newVideo(3))
+	entdb.Remove(uint(1))
+
+	after := entdb.Index()
+
+	if after.Generation <= before.Generation {
+		t.Errorf("test index generation failed: got %v, wanted > %v", after.Generation, before.Generation)
+	}
+
+	// Published generation never changes under readers
+	Expected := 2
+	Got := len(before.ByTag("tag-1"))
+	if Got != Expected {
+		t.Errorf("test old generation tag index failed: got %v, wanted %v", Got, Expected)
+	}
+	if _, exists := before.Video(uint(1)); !exists {
+		t.Errorf("test old generation should still have video 1")
+	}
+	if _, exists := before.Video(uint(3)); exists {
+		t.Errorf("test old generation should not have video 3")
+	}
+
+	Got = len(after.ByTag("tag-1"))
+	if Got != Expected {
+		t.Errorf("test new generation tag index failed: got %v, wanted %v", Got, Expected)
+	}
+	if _, exists := after.Video(uint(1)); exists {
+		t.Errorf("test new generation should not have video 1")
+	}
+	if after.Len() != len(entdb.Items) {
+		t.Errorf("test new generation items failed: got %v, wanted %v", after.Len(), len(entdb.Items))
+	}
+}
+
+func TestEntDBAddBatch(t *testing.T) {
+	entdb := NewEntDB(t.TempDir())
+
+	videos := make([]*EntVideo, 0)
+	for i := 1; i <= 10; i++ {
+		video := NewEntVideo(entdb)
+		video.Id = uint(i)
+		video.Title = fmt.Sprintf("batch title %d", i)
+		video.Slug = fmt.Sprintf("batch-title-%d", i)
+		videos = append(videos, video)
+	}
+
+	generation := entdb.Index().Generation
+	if err := entdb.AddBatch(videos); err != nil {
+		t.Fatalf("test add batch failed: %v", err)
+	}
+
+	Expected := generation + 1
+	Got := entdb.Index().Generation
+	if Got != Expected {
+		t.Errorf("test add batch publishes once failed: got %v, wanted %v", Got, Expected)
+	}
+	if len(entdb.Index().BySearch("batch")) != 10 {
+		t.Errorf("test add batch search failed: got %v, wanted %v", len(entdb.Index().BySearch("batch")), 10)
+	}
+}
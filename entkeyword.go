@@ -1,9 +1,5 @@
 package goentdb
 
-import (
-	"strings"
-)
-
 type EntKeyword struct {
 	Type   EntKeywordType
 	Id     int
@@ -17,7 +13,7 @@ func (ek *EntKeyword) GetMD5() string {
 
 func (ek *EntKeyword) GetSlug() string {
 	if ek.slug == "" {
-		ek.slug = strings.Replace(strings.Replace(strings.ToLower(ek.Phrase), " ", "-", -1), "#", "", -1)
+		ek.slug = EntSlug(ek.Phrase).GetSlug()
 	}
 	return ek.slug
 }
@@ -0,0 +1,319 @@
+package goentdb
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+type EntSort uint8
+
+const (
+	SortRelevance EntSort = iota
+	SortModifiedAt
+	SortDuration
+	SortId
+)
+
+const (
+	DefaultListLimit  = 20
+	DefaultCursorTTL  = 10 * time.Minute
+	DefaultCursorPins = 8 // Index generations kept for cursors, each one keeps its videos alive
+	maxPinnedLists    = 32
+)
+
+var ErrInvalidCursor = errors.New("invalid listing cursor")
+
+/*
+Listing of the videos matching all of Tag, Model and Search, an empty
+request lists every video. Pages come either from Offset or from the
+Cursor of the previous page.
+*/
+type EntListRequest struct {
+	Tag    string // Tag slug
+	Model  string // Model slug
+	Search string // Text query, see EntAnalyzer.ParseSearchQuery
+	Sort   EntSort
+	Asc    bool // Lowest first, by default the most relevant, newest, longest or highest Id come first
+	Offset int
+	Limit  int              // Page size, 0 uses DefaultListLimit
+	Cursor string           // EntListPage.Next of the previous page
+	Facets *EntFacetRequest // Facets of every matching video, not only of the page
+}
+
+type EntListPage struct {
+	Results []EntSearchResult
+	Total   int
+	Next    string     // Cursor of the next page, empty on the last page
+	Facets  *EntFacets // Set when the request asked for facets
+}
+
+/*
+Position after the last result of a page.
+Offset is used while the index generation the page came from is pinned,
+afterwards the listing continues after the sort key Value and Id.
+*/
+type entListCursor struct {
+	Generation  uint64  `json:"g"`
+	Offset      int     `json:"o"`
+	Value       float64 `json:"v"`
+	Id          uint    `json:"i"`
+	Fingerprint uint32  `json:"f"`
+}
+
+/*
+Index generation kept for cursors with its sorted listings by request key,
+so the pages after the first are cut without sorting again
+*/
+type entPin struct {
+	index   *EntIndex
+	expires time.Time
+	lists   map[string][]EntSearchResult
+}
+
+/*
+Fields which select and order the listing, two requests with the same key
+list the same videos
+*/
+func (req *EntListRequest) key() string {
+	return fmt.Sprintf("%s\x00%s\x00%s\x00%d\x00%v", req.Tag, req.Model, req.Search, req.Sort, req.Asc)
+}
+
+/*
+Short hash of key, cursors carry it to catch a cursor reused for another listing
+*/
+func (req *EntListRequest) fingerprint() uint32 {
+	return hashString(req.key())
+}
+
+func (req *EntListRequest) value(result EntSearchResult) float64 {
+	switch req.Sort {
+	case SortModifiedAt:
+		return float64(result.Video.ModifiedAt.UnixMilli())
+	case SortDuration:
+		return float64(result.Video.Duration)
+	case SortId:
+		return float64(result.Video.Id)
+	}
+	return result.Score
+}
+
+/*
+Order of the listing, ties are broken by Id in the same direction
+*/
+func (req *EntListRequest) less(value float64, id uint, otherValue float64, otherId uint) bool {
+	if value != otherValue {
+		return (value < otherValue) == req.Asc
+	}
+	if id == otherId {
+		return false
+	}
+	return (id < otherId) == req.Asc
+}
+
+func encodeListCursor(cursor *entListCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeListCursor(value string) (*entListCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	cursor := &entListCursor{}
+	if err := json.Unmarshal(data, cursor); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return cursor, nil
+}
+
+/*
+Every video matching req in listing order
+*/
+func (idx *EntIndex) list(req *EntListRequest, ranking EntRanking) []EntSearchResult {
+	var res []EntSearchResult
+
+	switch {
+	case req.Search != "":
+		res = idx.Search(idx.Analyzer().ParseSearchQuery(req.Search), ranking)
+	case req.Tag != "":
+		res = videoResults(distinctVideos(idx.ByTag(req.Tag)))
+	case req.Model != "":
+		res = videoResults(distinctVideos(idx.ByModel(req.Model)))
+	default:
+		res = videoResults(idx.Items)
+	}
+
+	filtered := make([]EntSearchResult, 0, len(res))
+	for _, result := range res {
+		if req.Tag != "" && !queryTag(req.Tag).match(idx, result.Video) {
+			continue
+		}
+		if req.Model != "" && !queryModel(req.Model).match(idx, result.Video) {
+			continue
+		}
+		filtered = append(filtered, result)
+	}
+
+	sort.Slice(filtered, func(i, j int) bool {
+		return req.less(req.value(filtered[i]), filtered[i].Video.Id, req.value(filtered[j]), filtered[j].Video.Id)
+	})
+
+	return filtered
+}
+
+func videoResults(videos []*EntVideo) []EntSearchResult {
+	res := make([]EntSearchResult, len(videos))
+	for i, video := range videos {
+		res[i] = EntSearchResult{Video: video}
+	}
+	return res
+}
+
+/*
+Page of a sorted listing with the total number of matching videos.
+Pages stay stable under concurrent writes: the next page is cut from the same
+index generation for CursorTTL after the previous one, after that it starts
+right after the last video of the previous page in the current generation.
+The sorted listing is kept with the generation, Results are shared and must
+not be modified.
+*/
+func (edb *EntDB) List(req EntListRequest) (*EntListPage, error) {
+	if req.Limit <= 0 {
+		req.Limit = DefaultListLimit
+	}
+	if req.Offset < 0 {
+		return nil, fmt.Errorf("negative listing offset %d", req.Offset)
+	}
+
+	idx := edb.Index()
+	offset := req.Offset
+	key := req.key()
+	_, results := edb.pinned(idx.Generation, key)
+
+	var after *entListCursor
+	if req.Cursor != "" {
+		cursor, err := decodeListCursor(req.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if cursor.Fingerprint != req.fingerprint() {
+			return nil, fmt.Errorf("%w: cursor belongs to another listing", ErrInvalidCursor)
+		}
+
+		if pinned, list := edb.pinned(cursor.Generation, key); pinned != nil {
+			idx, offset, results = pinned, cursor.Offset, list
+		} else {
+			after = cursor
+		}
+	}
+
+	if results == nil {
+		results = idx.list(&req, edb.Ranking)
+	}
+
+	if after != nil {
+		offset = sort.Search(len(results), func(i int) bool {
+			return req.less(after.Value, after.Id, req.value(results[i]), results[i].Video.Id)
+		})
+	}
+
+	start := Min(offset, len(results))
+	end := Min(start+req.Limit, len(results))
+	page := &EntListPage{Results: results[start:end], Total: len(results)}
+	if req.Facets != nil {
+		page.Facets = idx.Facets(results, *req.Facets)
+	}
+
+	if end < len(results) {
+		last := results[end-1]
+		edb.pin(idx, key, results)
+		page.Next = encodeListCursor(&entListCursor{
+			Generation:  idx.Generation,
+			Offset:      end,
+			Value:       req.value(last),
+			Id:          last.Video.Id,
+			Fingerprint: req.fingerprint(),
+		})
+	}
+
+	return page, nil
+}
+
+func (edb *EntDB) cursorTTL() time.Duration {
+	if edb.CursorTTL > 0 {
+		return edb.CursorTTL
+	}
+	return DefaultCursorTTL
+}
+
+func (edb *EntDB) cursorPins() int {
+	if edb.CursorPins > 0 {
+		return edb.CursorPins
+	}
+	return DefaultCursorPins
+}
+
+/*
+Keep the index generation and the sorted listing for the cursors pointing
+into it. At most cursorPins generations are kept, the one expiring first
+makes room, its cursors continue after their last video instead.
+*/
+func (edb *EntDB) pin(idx *EntIndex, key string, results []EntSearchResult) {
+	edb.pinLock.Lock()
+	defer edb.pinLock.Unlock()
+
+	now := time.Now()
+	for generation, pin := range edb.pins {
+		if now.After(pin.expires) {
+			delete(edb.pins, generation)
+		}
+	}
+
+	pin, exists := edb.pins[idx.Generation]
+	if !exists {
+		for len(edb.pins) >= edb.cursorPins() {
+			var oldest *entPin
+			for _, pin := range edb.pins {
+				if oldest == nil || pin.expires.Before(oldest.expires) {
+					oldest = pin
+				}
+			}
+			delete(edb.pins, oldest.index.Generation)
+		}
+
+		pin = &entPin{index: idx, lists: make(map[string][]EntSearchResult)}
+		edb.pins[idx.Generation] = pin
+	}
+	pin.expires = now.Add(edb.cursorTTL())
+
+	if _, cached := pin.lists[key]; !cached && len(pin.lists) >= maxPinnedLists {
+		for other := range pin.lists {
+			delete(pin.lists, other)
+			break
+		}
+	}
+	pin.lists[key] = results
+}
+
+/*
+Pinned index generation and its sorted listing for the request key, the listing
+is nil when it is not kept
+*/
+func (edb *EntDB) pinned(generation uint64, key string) (*EntIndex, []EntSearchResult) {
+	edb.pinLock.Lock()
+	defer edb.pinLock.Unlock()
+
+	pin, exists := edb.pins[generation]
+	if !exists || time.Now().After(pin.expires) {
+		return nil, nil
+	}
+
+	return pin.index, pin.lists[key]
+}
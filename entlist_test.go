@@ -0,0 +1,159 @@
+package goentdb
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func GenerateListEntDB(path string, videos int) *EntDB {
+	entdb := NewEntDB(path)
+	entdb.AddTag(NewTag(1, "tag 1"))
+
+	for i := 1; i <= videos; i++ {
+		entdb.Add(newListVideo(entdb, i))
+	}
+
+	return entdb
+}
+
+func newListVideo(entdb *EntDB, id int) *EntVideo {
+	video := NewEntVideo(entdb)
+	video.Id = uint(id)
+	video.Title = fmt.Sprintf("listed title %d", id)
+	video.Slug = fmt.Sprintf("listed-title-%d", id)
+	video.Duration = id % 7
+	video.ModifiedAt = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(id) * time.Hour)
+	video.AddTag(entdb.DictTags[1])
+	return video
+}
+
+func listAll(t *testing.T, entdb *EntDB, req EntListRequest, write func(page int)) []uint {
+	ids := make([]uint, 0)
+
+	for page := 0; ; page++ {
+		res, err := entdb.List(req)
+		if err != nil {
+			t.Fatalf("test list page %d failed: %v", page, err)
+		}
+		for _, result := range res.Results {
+			ids = append(ids, result.Video.Id)
+		}
+		if res.Next == "" {
+			return ids
+		}
+		req.Cursor = res.Next
+		write(page)
+	}
+}
+
+func TestEntDBList(t *testing.T) {
+	entdb := GenerateListEntDB(t.TempDir(), 25)
+
+	res, err := entdb.List(EntListRequest{Tag: "tag-1", Sort: SortModifiedAt, Offset: 20, Limit: 10})
+	if err != nil {
+		t.Fatalf("test list offset failed: %v", err)
+	}
+	if res.Total != 25 || len(res.Results) != 5 || res.Next != "" {
+		t.Errorf("test list offset failed: got %v %v %q, wanted %v %v", res.Total, len(res.Results), res.Next, 25, 5)
+	}
+	// Newest first
+	if res.Results[0].Video.Id != 5 {
+		t.Errorf("test list offset order failed: got %v, wanted %v", res.Results[0].Video.Id, 5)
+	}
+
+	res, _ = entdb.List(EntListRequest{Sort: SortDuration, Asc: true, Limit: 3})
+	Expected := []uint{7, 14, 21}
+	for i, result := range res.Results {
+		if result.Video.Id != Expected[i] {
+			t.Errorf("test list by duration failed: got %v, wanted %v", result.Video.Id, Expected[i])
+		}
+	}
+
+	res, _ = entdb.List(EntListRequest{Search: "listed title 12", Limit: 1})
+	if res.Total != 25 || res.Results[0].Video.Id != 12 {
+		t.Errorf("test list by relevance failed: got %v %v, wanted %v %v", res.Total, res.Results[0].Video.Id, 25, 12)
+	}
+
+	res, _ = entdb.List(EntListRequest{Tag: "tag-1", Limit: 10})
+	if _, err := entdb.List(EntListRequest{Tag: "tag-1", Sort: SortDuration, Cursor: res.Next}); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("test list cursor of another listing failed: got %v, wanted %v", err, ErrInvalidCursor)
+	}
+	if _, err := entdb.List(EntListRequest{Cursor: "garbage!"}); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("test list bad cursor failed: got %v, wanted %v", err, ErrInvalidCursor)
+	}
+}
+
+func TestEntDBListStable(t *testing.T) {
+	for _, ttl := range []time.Duration{time.Minute, time.Nanosecond} {
+		entdb := GenerateListEntDB(t.TempDir(), 25)
+		entdb.CursorTTL = ttl
+
+		// Writes between pages: a newer video and removal of one not listed yet
+		next := 100
+		ids := listAll(t, entdb, EntListRequest{Tag: "tag-1", Sort: SortModifiedAt, Limit: 10}, func(page int) {
+			entdb.Add(newListVideo(entdb, next))
+			next++
+			if page == 0 {
+				entdb.Remove(uint(3))
+			}
+		})
+
+		seen := make(map[uint]bool)
+		for _, id := range ids {
+			if seen[id] {
+				t.Errorf("test list stable with ttl %v failed: video %v listed twice", ttl, id)
+			}
+			seen[id] = true
+		}
+
+		Expected := 25
+		if ttl == time.Nanosecond {
+			// The next page starts after the previous one in the current generation
+			Expected = 24
+		}
+		if len(ids) != Expected || ids[0] != 25 {
+			t.Errorf("test list stable with ttl %v failed: got %v, wanted %v", ttl, ids, Expected)
+		}
+	}
+}
+
+func TestEntDBListPins(t *testing.T) {
+	entdb := GenerateListEntDB(t.TempDir(), 25)
+	entdb.CursorPins = 2
+
+	req := EntListRequest{Sort: SortId, Limit: 5}
+	next := 100
+	listAll(t, entdb, req, func(page int) {
+		entdb.Add(newListVideo(entdb, next))
+		next++
+	})
+
+	entdb.pinLock.Lock()
+	pins := len(entdb.pins)
+	entdb.pinLock.Unlock()
+	if pins > 2 {
+		t.Errorf("test list pins bound failed: got %v, wanted at most %v", pins, 2)
+	}
+
+	// The next page is cut from the cached listing of the first one
+	first, _ := entdb.List(req)
+	if _, Got := entdb.pinned(entdb.Index().Generation, req.key()); len(Got) != first.Total {
+		t.Errorf("test list pins cache failed: got %v, wanted %v", len(Got), first.Total)
+	}
+	second, err := entdb.List(EntListRequest{Sort: SortId, Limit: 5, Cursor: first.Next})
+	if err != nil || second.Results[0].Video.Id != first.Results[4].Video.Id-1 {
+		t.Errorf("test list pins next page failed: got %v, %v", second, err)
+	}
+
+	// Listings are kept by the whole request, not by its fingerprint
+	asc := EntListRequest{Sort: SortId, Asc: true, Limit: 5}
+	entdb.pin(entdb.Index(), asc.key(), first.Results)
+	if _, Got := entdb.pinned(entdb.Index().Generation, "other"); Got != nil {
+		t.Errorf("test list pins should not serve another listing: got %v", len(Got))
+	}
+	if _, Got := entdb.pinned(entdb.Index().Generation, req.key()); len(Got) != first.Total {
+		t.Errorf("test list pins cache of two listings failed: got %v, wanted %v", len(Got), first.Total)
+	}
+}
@@ -0,0 +1,156 @@
+package goentdb
+
+import (
+	"html"
+	"strings"
+)
+
+/*
+Text search query, quoted parts of the query string are phrases
+*/
+type EntSearchQuery struct {
+	Words   []string   // Search terms, a video with any of them in the title matches
+	Phrases [][]string // Title tokens of the quoted phrases, all of them must be in the title
+	runs    [][]string // Title tokens of the query, their n-grams boost the score
+}
+
+/*
+Split Query into words and "quoted phrases", an unterminated quote runs to the end.
+Words are analyzed with the analyzer of DefaultLanguage.
+*/
+func ParseSearchQuery(Query string) *EntSearchQuery {
+	return GetAnalyzer(DefaultLanguage).ParseSearchQuery(Query)
+}
+
+/*
+ParseSearchQuery with the words analyzed by a, phrases match the words as typed
+*/
+func (a *EntAnalyzer) ParseSearchQuery(Query string) *EntSearchQuery {
+	text := strings.Replace(Query, `"`, " ", -1)
+	query := &EntSearchQuery{
+		Words:   a.queryTerms(text),
+		Phrases: make([][]string, 0),
+		runs:    [][]string{phraseTokens(text)},
+	}
+
+	for i, part := range strings.Split(Query, `"`) {
+		if tokens := phraseTokens(part); i%2 == 1 && len(tokens) > 0 {
+			query.Phrases = append(query.Phrases, tokens)
+		}
+	}
+
+	return query
+}
+
+/*
+2- and 3-grams of the query as they are keyed in the TwoGrams and ThreeGrams indexes
+*/
+func (q *EntSearchQuery) grams() []string {
+	seen := make(map[string]bool)
+	res := make([]string, 0)
+
+	for _, run := range q.runs {
+		for n := 2; n <= 3; n++ {
+			for i := 0; i+n <= len(run); i++ {
+				gram := strings.Join(run[i:i+n], " ")
+				if !seen[gram] {
+					seen[gram] = true
+					res = append(res, gram)
+				}
+			}
+		}
+	}
+
+	return res
+}
+
+/*
+Tokens of text as EntVideo.GetTitleTokens splits a title for the n-gram indexes
+*/
+func phraseTokens(text string) []string {
+	return Tokenizer.Tokens(html.UnescapeString(text))
+}
+
+/*
+Videos with the phrase in the title.
+Phrases of 2 and 3 words are looked up in the n-gram indexes, longer ones
+intersect the postings of their 3-grams and are checked against the title.
+*/
+func (idx *EntIndex) ByPhrase(phrase string) []*EntVideo {
+	return idx.byPhrase(phraseTokens(phrase))
+}
+
+func (idx *EntIndex) byPhrase(tokens []string) []*EntVideo {
+	switch len(tokens) {
+	case 0:
+		return nil
+	case 1:
+		// The Search index is keyed by stems, other words with the stem are skipped
+		res := make([]*EntVideo, 0)
+		for _, video := range distinctVideos(idx.BySearch(idx.Analyzer().Stem(tokens[0]))) {
+			if containsTokens(video.GetTitleTokens(false), tokens) {
+				res = append(res, video)
+			}
+		}
+		return res
+	case 2:
+		return distinctVideos(idx.ByTwoGram(strings.Join(tokens, " ")))
+	case 3:
+		return distinctVideos(idx.ByThreeGram(strings.Join(tokens, " ")))
+	}
+
+	var candidates map[*EntVideo]bool
+	for i := 0; i+3 <= len(tokens); i++ {
+		matched := make(map[*EntVideo]bool)
+		for _, video := range idx.ByThreeGram(strings.Join(tokens[i:i+3], " ")) {
+			if candidates == nil || candidates[video] {
+				matched[video] = true
+			}
+		}
+		candidates = matched
+	}
+
+	// Every 3-gram matching does not mean they follow each other
+	res := make([]*EntVideo, 0)
+	for _, video := range idx.ByThreeGram(strings.Join(tokens[:3], " ")) {
+		if candidates[video] && containsTokens(video.GetTitleTokens(false), tokens) {
+			res = append(res, video)
+			delete(candidates, video)
+		}
+	}
+
+	return res
+}
+
+func containsTokens(title []string, tokens []string) bool {
+	for i := 0; i+len(tokens) <= len(title); i++ {
+		found := true
+		for j, token := range tokens {
+			if title[i+j] != token {
+				found = false
+				break
+			}
+		}
+		if found {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+Posting lists hold a video once for every time a token is in its title
+*/
+func distinctVideos(videos []*EntVideo) []*EntVideo {
+	seen := make(map[*EntVideo]bool, len(videos))
+	res := make([]*EntVideo, 0, len(videos))
+
+	for _, video := range videos {
+		if !seen[video] {
+			seen[video] = true
+			res = append(res, video)
+		}
+	}
+
+	return res
+}
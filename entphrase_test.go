@@ -0,0 +1,73 @@
+package goentdb
+
+import (
+	"testing"
+)
+
+func TestEntIndexByPhrase(t *testing.T) {
+	entdb := GenerateSearchEntDB(t.TempDir(), []string{
+		"big red car for sale",
+		"red car is big",
+		"a Big Red Car",
+		"big red car then a red car crash",
+		"the big red car crash",
+	})
+	idx := entdb.Index()
+
+	cases := []struct {
+		Phrase   string
+		Expected []uint
+	}{
+		{Phrase: "red car", Expected: []uint{1, 2, 3, 4, 5}},
+		{Phrase: "big red car", Expected: []uint{1, 3, 4, 5}},
+		// Both 3-grams are in video 4 but not one after the other
+		{Phrase: "big red car crash", Expected: []uint{5}},
+		{Phrase: "car is big", Expected: []uint{2}},
+		{Phrase: "blue car", Expected: []uint{}},
+	}
+
+	for _, c := range cases {
+		Got := idx.ByPhrase(c.Phrase)
+		if len(Got) != len(c.Expected) {
+			t.Errorf("test by phrase %q failed: got %v, wanted %v", c.Phrase, len(Got), len(c.Expected))
+			continue
+		}
+		for i, video := range Got {
+			if video.Id != c.Expected[i] {
+				t.Errorf("test by phrase %q failed: got %v, wanted %v", c.Phrase, video.Id, c.Expected[i])
+			}
+		}
+	}
+}
+
+func TestEntDBSearchPhrase(t *testing.T) {
+	entdb := GenerateSearchEntDB(t.TempDir(), []string{
+		"car big and red",
+		"red car big",
+		"big red car",
+		"big car",
+	})
+
+	// Unquoted words match any title, the phrase ranks first
+	results, total := entdb.SearchRanked("big red car", 10)
+	if total != 4 {
+		t.Errorf("test search phrase total failed: got %v, wanted %v", total, 4)
+	}
+	if results[0].Video.Id != 3 {
+		t.Errorf("test search phrase rank failed: got %v, wanted %v", results[0].Video.Id, 3)
+	}
+	if results[1].Video.Id != 2 {
+		t.Errorf("test search 2-gram rank failed: got %v, wanted %v", results[1].Video.Id, 2)
+	}
+
+	// Quoted phrase is required
+	results, total = entdb.SearchRanked(`big "red car"`, 10)
+	if total != 2 || results[0].Video.Id != 3 {
+		t.Errorf("test search quoted phrase failed: got %v %v, wanted %v %v", total, results[0].Video.Id, 2, 3)
+	}
+
+	query := ParseSearchQuery(`big "red car`)
+	if len(query.Phrases) != 1 || len(query.Words) != 3 {
+		t.Errorf("test parse unterminated quote failed: got %v %v", query.Phrases, query.Words)
+	}
+}
@@ -0,0 +1,613 @@
+package goentdb
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+Boolean query parsed by ParseQuery
+*/
+type EntQuery struct {
+	root queryNode
+	text *EntSearchQuery // Words and phrases outside of NOT, they rank the matches
+}
+
+/*
+Query which failed to parse, Pos is the byte offset of the problem in Query
+*/
+type EntQueryError struct {
+	Query string
+	Pos   int
+	Msg   string
+}
+
+func (e *EntQueryError) Error() string {
+	return fmt.Sprintf("query %q: %s at position %d", e.Query, e.Msg, e.Pos)
+}
+
+type queryNode interface {
+	match(idx *EntIndex, video *EntVideo) bool
+	// Videos which may match, false when every video has to be checked
+	candidates(idx *EntIndex) ([]*EntVideo, bool)
+}
+
+type (
+	queryAnd    []queryNode
+	queryOr     []queryNode
+	queryNot    struct{ node queryNode }
+	queryTag    string // Slug
+	queryModel  string // Slug
+	queryWord   string // Search term
+	queryPhrase []string
+	queryOrigin Origin
+	queryRange  struct {
+		value  func(video *EntVideo) int64
+		lo, hi int64 // lo <= value < hi
+	}
+)
+
+func (q queryAnd) match(idx *EntIndex, video *EntVideo) bool {
+	for _, node := range q {
+		if !node.match(idx, video) {
+			return false
+		}
+	}
+	return true
+}
+
+func (q queryAnd) candidates(idx *EntIndex) ([]*EntVideo, bool) {
+	var res []*EntVideo
+	found := false
+
+	// Smallest posting list of the terms, the other terms are matched on it
+	for _, node := range q {
+		if videos, ok := node.candidates(idx); ok && (!found || len(videos) < len(res)) {
+			res, found = videos, true
+		}
+	}
+
+	return res, found
+}
+
+func (q queryOr) match(idx *EntIndex, video *EntVideo) bool {
+	for _, node := range q {
+		if node.match(idx, video) {
+			return true
+		}
+	}
+	return false
+}
+
+func (q queryOr) candidates(idx *EntIndex) ([]*EntVideo, bool) {
+	res := make([]*EntVideo, 0)
+	for _, node := range q {
+		videos, ok := node.candidates(idx)
+		if !ok {
+			return nil, false
+		}
+		res = append(res, videos...)
+	}
+	return res, true
+}
+
+func (q queryNot) match(idx *EntIndex, video *EntVideo) bool {
+	return !q.node.match(idx, video)
+}
+
+func (q queryNot) candidates(idx *EntIndex) ([]*EntVideo, bool) {
+	return nil, false
+}
+
+func (q queryTag) match(idx *EntIndex, video *EntVideo) bool {
+	slug := idx.synonyms.tags.slug(string(q))
+	for _, tag := range video.Tags {
+		if idx.synonyms.tags.slug(tag.GetSlug()) == slug {
+			return true
+		}
+	}
+	return false
+}
+
+func (q queryTag) candidates(idx *EntIndex) ([]*EntVideo, bool) {
+	return idx.ByTag(string(q)), true
+}
+
+func (q queryModel) match(idx *EntIndex, video *EntVideo) bool {
+	slug := idx.synonyms.models.slug(string(q))
+	for _, model := range video.Models {
+		if idx.synonyms.models.slug(model.GetSlug()) == slug {
+			return true
+		}
+	}
+	return false
+}
+
+func (q queryModel) candidates(idx *EntIndex) ([]*EntVideo, bool) {
+	return idx.ByModel(string(q)), true
+}
+
+func (q queryWord) match(idx *EntIndex, video *EntVideo) bool {
+	terms := idx.synonyms.expand([]string{string(q)})
+	for _, token := range video.GetSearchTokens() {
+		for _, term := range terms {
+			if token == term {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (q queryWord) candidates(idx *EntIndex) ([]*EntVideo, bool) {
+	res := make([]*EntVideo, 0)
+	for _, term := range idx.synonyms.expand([]string{string(q)}) {
+		res = append(res, idx.BySearch(term)...)
+	}
+	return res, true
+}
+
+func (q queryPhrase) match(idx *EntIndex, video *EntVideo) bool {
+	return containsTokens(video.GetTitleTokens(false), q)
+}
+
+func (q queryPhrase) candidates(idx *EntIndex) ([]*EntVideo, bool) {
+	// Single words shorter than a search token are not in any index
+	if len(q) < 2 {
+		return nil, false
+	}
+	return idx.byPhrase(q), true
+}
+
+func (q queryOrigin) match(idx *EntIndex, video *EntVideo) bool {
+	return video.Origin == Origin(q)
+}
+
+func (q queryOrigin) candidates(idx *EntIndex) ([]*EntVideo, bool) {
+	return nil, false
+}
+
+func (q queryRange) match(idx *EntIndex, video *EntVideo) bool {
+	value := q.value(video)
+	return q.lo <= value && value < q.hi
+}
+
+func (q queryRange) candidates(idx *EntIndex) ([]*EntVideo, bool) {
+	return nil, false
+}
+
+type queryParser struct {
+	query    string
+	pos      int
+	text     *EntSearchQuery
+	analyzer *EntAnalyzer
+}
+
+/*
+Parse a boolean query:
+
+	tag:blonde -tag:outdoor model:"jane doe" duration:>600 origin:xvideos "exact phrase"
+
+Terms next to each other or joined by AND must all match, OR matches either
+side and binds looser than AND, NOT or a leading - negates a term, parentheses
+group terms. Fields are tag, model and origin, and the ranges duration (seconds
+or a Go duration like 10m) and modified (2006-01-02 or RFC 3339). Ranges take
+=, >, >=, <, <= or from..to with either end left open. Plain words and quoted
+phrases match the title, words are analyzed with the analyzer of DefaultLanguage.
+Words too short or too common to search are dropped, only a query left without
+any term fails.
+*/
+func ParseQuery(Query string) (*EntQuery, error) {
+	return GetAnalyzer(DefaultLanguage).ParseQuery(Query)
+}
+
+/*
+ParseQuery with the words analyzed by a
+*/
+func (a *EntAnalyzer) ParseQuery(Query string) (*EntQuery, error) {
+	p := &queryParser{
+		query:    Query,
+		text:     &EntSearchQuery{Words: make([]string, 0), Phrases: make([][]string, 0), runs: make([][]string, 0)},
+		analyzer: a,
+	}
+
+	root, err := p.parseOr(false)
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipSpace()
+	if p.pos < len(p.query) {
+		return nil, p.errorf(p.pos, "unexpected %q", p.query[p.pos])
+	}
+
+	if root == nil {
+		return nil, p.errorf(0, "every word is too short or too common to search")
+	}
+
+	return &EntQuery{root: root, text: p.text}, nil
+}
+
+func (p *queryParser) errorf(pos int, format string, args ...interface{}) error {
+	return &EntQueryError{Query: p.query, Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *queryParser) skipSpace() {
+	for p.pos < len(p.query) && p.query[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+func isQueryDelimiter(char byte) bool {
+	return char == ' ' || char == '(' || char == ')' || char == '"'
+}
+
+/*
+Consume keyword when it is a whole word at the current position
+*/
+func (p *queryParser) keyword(keyword string) bool {
+	p.skipSpace()
+
+	end := p.pos + len(keyword)
+	if !strings.HasPrefix(p.query[p.pos:], keyword) || (end < len(p.query) && !isQueryDelimiter(p.query[end])) {
+		return false
+	}
+
+	p.pos = end
+	return true
+}
+
+/*
+Parsers return a nil node for terms which are dropped, like a stop word
+*/
+func (p *queryParser) parseOr(negated bool) (queryNode, error) {
+	node, err := p.parseAnd(negated)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := queryOr{}
+	if node != nil {
+		nodes = append(nodes, node)
+	}
+	for p.keyword("OR") {
+		node, err := p.parseAnd(negated)
+		if err != nil {
+			return nil, err
+		}
+		if node != nil {
+			nodes = append(nodes, node)
+		}
+	}
+
+	switch len(nodes) {
+	case 0:
+		return nil, nil
+	case 1:
+		return nodes[0], nil
+	}
+	return nodes, nil
+}
+
+func (p *queryParser) parseAnd(negated bool) (queryNode, error) {
+	nodes := queryAnd{}
+	terms := 0
+
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.query) || p.query[p.pos] == ')' {
+			break
+		}
+
+		start := p.pos
+		if p.keyword("OR") {
+			p.pos = start
+			break
+		}
+		p.keyword("AND")
+
+		node, err := p.parseUnary(negated)
+		if err != nil {
+			return nil, err
+		}
+		terms++
+		if node != nil {
+			nodes = append(nodes, node)
+		}
+	}
+
+	switch {
+	case terms == 0:
+		return nil, p.errorf(p.pos, "expected a term")
+	case len(nodes) == 0:
+		return nil, nil
+	case len(nodes) == 1:
+		return nodes[0], nil
+	}
+	return nodes, nil
+}
+
+func (p *queryParser) parseUnary(negated bool) (queryNode, error) {
+	p.skipSpace()
+
+	negate := p.keyword("NOT")
+	if !negate && p.pos < len(p.query) && p.query[p.pos] == '-' {
+		p.pos++
+		negate = true
+	}
+
+	if negate {
+		node, err := p.parseUnary(!negated)
+		if err != nil || node == nil {
+			return nil, err
+		}
+		return queryNot{node}, nil
+	}
+
+	return p.parsePrimary(negated)
+}
+
+func (p *queryParser) parsePrimary(negated bool) (queryNode, error) {
+	p.skipSpace()
+	start := p.pos
+
+	if p.pos >= len(p.query) {
+		return nil, p.errorf(p.pos, "expected a term")
+	}
+
+	switch p.query[p.pos] {
+	case '(':
+		p.pos++
+		node, err := p.parseOr(negated)
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if p.pos >= len(p.query) || p.query[p.pos] != ')' {
+			return nil, p.errorf(start, "missing closing parenthesis")
+		}
+		p.pos++
+		return node, nil
+	case ')':
+		return nil, p.errorf(start, "unexpected %q", ')')
+	case '"':
+		phrase, err := p.readPhrase()
+		if err != nil {
+			return nil, err
+		}
+		return p.phraseNode(start, phrase, negated)
+	}
+
+	word := p.readWord()
+	if field, value, found := strings.Cut(word, ":"); found {
+		return p.fieldNode(start, strings.ToLower(field), value)
+	}
+
+	return p.wordNode(word, negated)
+}
+
+func (p *queryParser) readWord() string {
+	start := p.pos
+	for p.pos < len(p.query) && !isQueryDelimiter(p.query[p.pos]) {
+		p.pos++
+	}
+	return p.query[start:p.pos]
+}
+
+func (p *queryParser) readPhrase() (string, error) {
+	start := p.pos
+	end := strings.IndexByte(p.query[start+1:], '"')
+	if end < 0 {
+		return "", p.errorf(start, "unterminated phrase")
+	}
+
+	p.pos = start + 1 + end + 1
+	return p.query[start+1 : start+1+end], nil
+}
+
+/*
+Word too short or too common to search is dropped, as text search ignores it
+*/
+func (p *queryParser) wordNode(word string, negated bool) (queryNode, error) {
+	tokens := p.analyzer.Terms(word)
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+
+	if !negated {
+		p.text.Words = append(p.text.Words, p.analyzer.queryTerms(word)...)
+	}
+
+	if len(tokens) == 1 {
+		return queryWord(tokens[0]), nil
+	}
+
+	nodes := queryAnd{}
+	for _, token := range tokens {
+		nodes = append(nodes, queryWord(token))
+	}
+	return nodes, nil
+}
+
+func (p *queryParser) phraseNode(start int, phrase string, negated bool) (queryNode, error) {
+	tokens := phraseTokens(phrase)
+	if len(tokens) == 0 {
+		return nil, p.errorf(start, "empty phrase")
+	}
+
+	if !negated {
+		p.text.Words = append(p.text.Words, p.analyzer.queryTerms(phrase)...)
+		p.text.Phrases = append(p.text.Phrases, tokens)
+		p.text.runs = append(p.text.runs, tokens)
+	}
+
+	return queryPhrase(tokens), nil
+}
+
+func (p *queryParser) fieldNode(start int, field string, value string) (queryNode, error) {
+	pos := start + len(field) + 1
+
+	if value == "" && p.pos < len(p.query) && p.query[p.pos] == '"' {
+		phrase, err := p.readPhrase()
+		if err != nil {
+			return nil, err
+		}
+		value = phrase
+	}
+	if value == "" {
+		return nil, p.errorf(pos, "missing value of %s", field)
+	}
+
+	switch field {
+	case "tag":
+		return queryTag(EntSlug(value).GetSlug()), nil
+	case "model":
+		return queryModel(EntSlug(value).GetSlug()), nil
+	case "origin":
+		origin, found := OriginByName(value)
+		if !found {
+			return nil, p.errorf(pos, "unknown origin %q", value)
+		}
+		return queryOrigin(origin), nil
+	case "duration":
+		return p.rangeNode(pos, field, value, parseDurationValue, func(video *EntVideo) int64 {
+			return int64(video.Duration)
+		})
+	case "modified":
+		return p.rangeNode(pos, field, value, parseTimeValue, func(video *EntVideo) int64 {
+			return video.ModifiedAt.Unix()
+		})
+	}
+
+	return nil, p.errorf(start, "unknown field %q", field)
+}
+
+/*
+Range filter, parse returns the interval [lo, hi) a single value stands for,
+e.g. the whole day for a date
+*/
+func (p *queryParser) rangeNode(pos int, field string, value string, parse func(string) (int64, int64, bool), get func(*EntVideo) int64) (queryNode, error) {
+	node := queryRange{value: get, lo: math.MinInt64, hi: math.MaxInt64}
+
+	if from, to, found := strings.Cut(value, ".."); found {
+		if from != "" {
+			lo, _, ok := parse(from)
+			if !ok {
+				return nil, p.errorf(pos, "bad %s %q", field, from)
+			}
+			node.lo = lo
+		}
+		if to != "" {
+			_, hi, ok := parse(to)
+			if !ok {
+				return nil, p.errorf(pos+len(from)+2, "bad %s %q", field, to)
+			}
+			node.hi = hi
+		}
+		return node, nil
+	}
+
+	op := ""
+	for _, prefix := range []string{">=", "<=", ">", "<", "="} {
+		if strings.HasPrefix(value, prefix) {
+			op = prefix
+			break
+		}
+	}
+
+	lo, hi, ok := parse(value[len(op):])
+	if !ok {
+		return nil, p.errorf(pos+len(op), "bad %s %q", field, value[len(op):])
+	}
+
+	switch op {
+	case ">":
+		node.lo = hi
+	case ">=":
+		node.lo = lo
+	case "<":
+		node.hi = lo
+	case "<=":
+		node.hi = hi
+	default:
+		node.lo, node.hi = lo, hi
+	}
+
+	return node, nil
+}
+
+/*
+Seconds or a Go duration
+*/
+func parseDurationValue(value string) (int64, int64, bool) {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return seconds, seconds + 1, true
+	}
+	if duration, err := time.ParseDuration(value); err == nil {
+		seconds := int64(duration / time.Second)
+		return seconds, seconds + 1, true
+	}
+	return 0, 0, false
+}
+
+/*
+Date for the whole day or RFC 3339 time for the second, in Unix seconds
+*/
+func parseTimeValue(value string) (int64, int64, bool) {
+	if day, err := time.Parse("2006-01-02", value); err == nil {
+		return day.Unix(), day.AddDate(0, 0, 1).Unix(), true
+	}
+	if moment, err := time.Parse(time.RFC3339, value); err == nil {
+		return moment.Unix(), moment.Unix() + 1, true
+	}
+	return 0, 0, false
+}
+
+/*
+Videos matching query, ranked by the words and phrases outside of NOT
+*/
+func (idx *EntIndex) Query(query *EntQuery, ranking EntRanking) []EntSearchResult {
+	ranking = ranking.orDefault()
+
+	candidates, ok := query.root.candidates(idx)
+	if !ok {
+		candidates = idx.Items
+	}
+
+	bm25 := idx.scoreBM25(idx.synonyms.expand(query.text.Words), ranking)
+	checked := make(map[*EntVideo]bool, len(candidates))
+	scores := make(map[*EntVideo]float64)
+
+	for _, video := range candidates {
+		if checked[video] {
+			continue
+		}
+		checked[video] = true
+
+		if query.root.match(idx, video) {
+			scores[video] = bm25[video]
+		}
+	}
+
+	idx.boostGrams(scores, query.text, ranking)
+
+	return sortSearchResults(scores)
+}
+
+/*
+Videos matching the boolean Query (see ParseQuery) with their scores,
+at most Size of them and the number of videos which matched
+*/
+func (edb *EntDB) Query(Query string, Size int) ([]EntSearchResult, int, error) {
+	idx := edb.Index()
+	query, err := idx.Analyzer().ParseQuery(Query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	res := idx.Query(query, edb.Ranking)
+	return res[:Min(len(res), Size)], len(res), nil
+}
@@ -0,0 +1,136 @@
+package goentdb
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func GenerateQueryEntDB(path string) *EntDB {
+	entdb := NewEntDB(path)
+	entdb.AddTag(NewTag(1, "blonde"))
+	entdb.AddTag(NewTag(2, "outdoor"))
+	entdb.AddModel(NewModel(1, "Jane Doe"))
+
+	videos := []struct {
+		Title    string
+		Origin   Origin
+		Duration int
+		Modified string
+		Tags     []int
+		Models   []int
+	}{
+		{"blonde on the beach", OriginXvideos, 300, "2024-01-10", []int{1, 2}, []int{1}},
+		{"blonde at home", OriginXvideos, 900, "2024-02-10", []int{1}, []int{1}},
+		{"jane at home again", OriginEporner, 1200, "2024-03-10", []int{1}, []int{1}},
+		{"an exact phrase title", OriginXvideos, 700, "2024-03-11", []int{2}, nil},
+		{"phrase exact title", OriginXvideos, 700, "2024-03-12", nil, nil},
+	}
+
+	for i, v := range videos {
+		video := NewEntVideo(entdb)
+		video.Id = uint(i + 1)
+		video.Title = v.Title
+		video.Slug = EntSlug(v.Title).GetSlug()
+		video.Origin = v.Origin
+		video.Duration = v.Duration
+		video.ModifiedAt, _ = time.Parse("2006-01-02", v.Modified)
+		for _, id := range v.Tags {
+			video.AddTag(entdb.DictTags[id])
+		}
+		for _, id := range v.Models {
+			video.AddModel(entdb.DictModels[id])
+		}
+		entdb.Add(video)
+	}
+
+	return entdb
+}
+
+func TestEntDBQuery(t *testing.T) {
+	entdb := GenerateQueryEntDB(t.TempDir())
+
+	cases := []struct {
+		Query    string
+		Expected []uint
+	}{
+		{Query: `tag:blonde -tag:outdoor model:"jane doe" duration:>600 origin:xvideos`, Expected: []uint{2}},
+		{Query: `tag:blonde`, Expected: []uint{1, 2, 3}},
+		{Query: `tag:blonde AND NOT tag:outdoor`, Expected: []uint{2, 3}},
+		{Query: `model:jane-doe origin:eporner`, Expected: []uint{3}},
+		{Query: `tag:outdoor OR origin:eporner`, Expected: []uint{1, 3, 4}},
+		{Query: `(tag:outdoor OR origin:eporner) duration:<=700`, Expected: []uint{1, 4}},
+		{Query: `duration:600..900`, Expected: []uint{2, 4, 5}},
+		{Query: `duration:..5m`, Expected: []uint{1}},
+		{Query: `modified:2024-03-11`, Expected: []uint{4}},
+		{Query: `modified:>=2024-03-10 -modified:>2024-03-11`, Expected: []uint{3, 4}},
+		{Query: `"exact phrase"`, Expected: []uint{4}},
+		{Query: `exact phrase`, Expected: []uint{4, 5}},
+		{Query: `home -"at home again"`, Expected: []uint{2}},
+		// Stop words and short words are dropped like in text search
+		{Query: `blonde at the beach`, Expected: []uint{1}},
+		{Query: `jane OR the`, Expected: []uint{3}},
+		{Query: `(the a) home -on`, Expected: []uint{2, 3}},
+	}
+
+	for _, c := range cases {
+		results, total, err := entdb.Query(c.Query, 10)
+		if err != nil {
+			t.Errorf("test query %s failed: %v", c.Query, err)
+			continue
+		}
+		if total != len(c.Expected) {
+			t.Errorf("test query %s failed: got %v, wanted %v", c.Query, total, len(c.Expected))
+			continue
+		}
+
+		matched := make(map[uint]bool)
+		for _, result := range results {
+			matched[result.Video.Id] = true
+		}
+		for _, id := range c.Expected {
+			if !matched[id] {
+				t.Errorf("test query %s failed: video %v not matched", c.Query, id)
+			}
+		}
+	}
+
+	// The phrase ranks above the words in another order
+	results, _, _ := entdb.Query(`exact phrase`, 10)
+	if results[0].Video.Id != 4 {
+		t.Errorf("test query rank failed: got %v, wanted %v", results[0].Video.Id, 4)
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	cases := []struct {
+		Query string
+		Pos   int
+	}{
+		{Query: `tag:`, Pos: 4},
+		{Query: `blonde (tag:a`, Pos: 7},
+		{Query: `blonde "abc`, Pos: 7},
+		{Query: `foo:bar`, Pos: 0},
+		{Query: `duration:>abc`, Pos: 10},
+		{Query: `duration:10..x`, Pos: 13},
+		{Query: `origin:nowhere`, Pos: 7},
+		{Query: `blonde OR`, Pos: 9},
+		{Query: `blonde)`, Pos: 6},
+		{Query: `-`, Pos: 1},
+		{Query: `a`, Pos: 0},
+		{Query: `the OR (a on)`, Pos: 0},
+	}
+
+	for _, c := range cases {
+		_, err := ParseQuery(c.Query)
+
+		var queryErr *EntQueryError
+		if !errors.As(err, &queryErr) {
+			t.Errorf("test parse %s failed: got %v, wanted EntQueryError", c.Query, err)
+			continue
+		}
+		if queryErr.Pos != c.Pos {
+			t.Errorf("test parse %s position failed: got %v, wanted %v (%v)", c.Query, queryErr.Pos, c.Pos, err)
+		}
+	}
+}
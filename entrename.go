@@ -0,0 +1,481 @@
+package goentdb
+
+import (
+	"errors"
+	"fmt"
+)
+
+var errEmptySlug = errors.New("phrase has an empty slug")
+
+/*
+Old slugs of renamed and merged tags and models -> slug they moved to.
+The maps are replaced rather than changed, published generations share them.
+*/
+type entRedirects struct {
+	Tags   map[string]string
+	Models map[string]string
+}
+
+func (r entRedirects) len() int {
+	return len(r.Tags) + len(r.Models)
+}
+
+/*
+Redirect from to to, redirects to from are moved on to to so a lookup
+never takes more than one hop
+*/
+func addRedirect(redirects map[string]string, from, to string) map[string]string {
+	res := make(map[string]string, len(redirects)+1)
+	for slug, target := range redirects {
+		if target == from {
+			target = to
+		}
+		res[slug] = target
+	}
+	res[from] = to
+	delete(res, to)
+	return res
+}
+
+func dropRedirect(redirects map[string]string, slug string) map[string]string {
+	if _, exists := redirects[slug]; !exists {
+		return redirects
+	}
+
+	res := make(map[string]string, len(redirects))
+	for from, to := range redirects {
+		if from != slug {
+			res[from] = to
+		}
+	}
+	return res
+}
+
+/*
+Slug the tag with slug was renamed or merged into, for 301s of old URLs
+*/
+func (idx *EntIndex) TagRedirect(slug string) (string, bool) {
+	to, exists := idx.redirects.Tags[slug]
+	return to, exists
+}
+
+func (idx *EntIndex) ModelRedirect(slug string) (string, bool) {
+	to, exists := idx.redirects.Models[slug]
+	return to, exists
+}
+
+func (edb *EntDB) TagRedirect(slug string) (string, bool) {
+	return edb.Index().TagRedirect(slug)
+}
+
+func (edb *EntDB) ModelRedirect(slug string) (string, bool) {
+	return edb.Index().ModelRedirect(slug)
+}
+
+/*
+Dictionary of tags or models with what renames and merges touch,
+taken with the lock held
+*/
+type entKeywordDict struct {
+	kind      EntKind
+	renameOp  EntWALOp
+	mergeOp   EntWALOp
+	dict      map[int]*EntKeyword
+	redirects *map[string]string
+	trie      *entTrie
+	keywords  func(video *EntVideo) *[]*EntKeyword
+	index     func(id int)
+}
+
+func (edb *EntDB) tagDict() entKeywordDict {
+	return entKeywordDict{
+		kind:      EntKindTag,
+		renameOp:  EntWALRenameTag,
+		mergeOp:   EntWALMergeTags,
+		dict:      edb.DictTags,
+		redirects: &edb.redirects.Tags,
+		trie:      &edb.next.tagTrie,
+		keywords:  func(video *EntVideo) *[]*EntKeyword { return &video.Tags },
+		index:     edb.indexTag,
+	}
+}
+
+func (edb *EntDB) modelDict() entKeywordDict {
+	return entKeywordDict{
+		kind:      EntKindModel,
+		renameOp:  EntWALRenameModel,
+		mergeOp:   EntWALMergeModels,
+		dict:      edb.DictModels,
+		redirects: &edb.redirects.Models,
+		trie:      &edb.next.modelTrie,
+		keywords:  func(video *EntVideo) *[]*EntKeyword { return &video.Models },
+		index:     edb.indexModel,
+	}
+}
+
+/*
+Rename tag id to phrase. The Tags index is re-keyed by the new slug, the
+videos of the tag reference the renamed tag and the old slug redirects to
+the new one. A phrase with the slug of another tag is a duplicate, see MergeTags.
+*/
+func (edb *EntDB) RenameTag(id int, phrase string) error {
+	return edb.mutate(func() error {
+		return edb.renameKeyword(edb.tagDict(), id, phrase)
+	})
+}
+
+func (edb *EntDB) RenameModel(id int, phrase string) error {
+	return edb.mutate(func() error {
+		return edb.renameKeyword(edb.modelDict(), id, phrase)
+	})
+}
+
+/*
+Merge tags ids into tag into. Their videos get tag into instead, the merged
+tags are dropped from DictTags and their slugs redirect to the slug of into.
+*/
+func (edb *EntDB) MergeTags(into int, ids ...int) error {
+	return edb.mutate(func() error {
+		return edb.mergeKeywords(edb.tagDict(), into, ids)
+	})
+}
+
+func (edb *EntDB) MergeModels(into int, ids ...int) error {
+	return edb.mutate(func() error {
+		return edb.mergeKeywords(edb.modelDict(), into, ids)
+	})
+}
+
+func (edb *EntDB) renameKeyword(d entKeywordDict, id int, phrase string) error {
+	old, exists := d.dict[id]
+	if !exists {
+		return notFound(d.kind, id)
+	}
+
+	renamed := NewEntKeyword(id, phrase, old.Type)
+	if renamed.GetSlug() == "" {
+		return fmt.Errorf("rename %s %d: %w", d.kind, id, errEmptySlug)
+	}
+	for other, keyword := range d.dict {
+		if other != id && keyword.GetSlug() == renamed.GetSlug() {
+			return duplicate(d.kind, renamed.GetSlug())
+		}
+	}
+
+	if err := edb.logMutation(&EntWALRecord{Op: d.renameOp, Keyword: renamed}); err != nil {
+		return err
+	}
+
+	edb.replaceKeywords(d, map[int]*EntKeyword{id: renamed})
+
+	return nil
+}
+
+func (edb *EntDB) mergeKeywords(d entKeywordDict, into int, ids []int) error {
+	target, exists := d.dict[into]
+	if !exists {
+		return notFound(d.kind, into)
+	}
+	for _, id := range ids {
+		if _, exists := d.dict[id]; !exists {
+			return notFound(d.kind, id)
+		}
+	}
+
+	if err := edb.logMutation(&EntWALRecord{Op: d.mergeOp, Keyword: target, Ids: ids}); err != nil {
+		return err
+	}
+
+	edb.replaceKeywords(d, mergeReplacements(target, ids))
+
+	return nil
+}
+
+func mergeReplacements(target *EntKeyword, ids []int) map[int]*EntKeyword {
+	res := make(map[int]*EntKeyword, len(ids))
+	for _, id := range ids {
+		if id != target.Id {
+			res[id] = target
+		}
+	}
+	return res
+}
+
+/*
+Replay skips keywords which are gone, the snapshot may already hold a later merge
+*/
+func (edb *EntDB) replayRename(d entKeywordDict, renamed *EntKeyword) {
+	if _, exists := d.dict[renamed.Id]; exists {
+		edb.replaceKeywords(d, map[int]*EntKeyword{renamed.Id: renamed})
+	}
+}
+
+func (edb *EntDB) replayMerge(d entKeywordDict, into int, ids []int) {
+	target, exists := d.dict[into]
+	if !exists {
+		return
+	}
+
+	present := make([]int, 0, len(ids))
+	for _, id := range ids {
+		if _, exists := d.dict[id]; exists {
+			present = append(present, id)
+		}
+	}
+
+	edb.replaceKeywords(d, mergeReplacements(target, present))
+}
+
+/*
+Replace the keywords of d by id, a replacement with another id drops the
+keyword from the dictionary. Videos are never changed in place: each video
+with a replaced keyword is swapped for a copy referencing the replacement.
+Old slugs no keyword has any more redirect to their replacement.
+Caller holds the lock.
+*/
+func (edb *EntDB) replaceKeywords(d entKeywordDict, replace map[int]*EntKeyword) {
+	if len(replace) == 0 {
+		return
+	}
+
+	slugs := make(map[int]string, len(replace))
+	for id, keyword := range replace {
+		old := d.dict[id]
+		slugs[id] = old.GetSlug()
+		d.trie.set(suggestKey(old.Phrase), nil)
+
+		if keyword.Id == id {
+			d.dict[id] = keyword
+		} else {
+			delete(d.dict, id)
+		}
+	}
+
+	swap := &entVideoSwap{moved: make(map[*EntVideo]*EntVideo)}
+	for _, video := range edb.Items {
+		affected := false
+		for _, keyword := range *d.keywords(video) {
+			if _, exists := replace[keyword.Id]; exists {
+				affected = true
+				break
+			}
+		}
+		if !affected {
+			continue
+		}
+
+		moved := *video
+		keywords := make([]*EntKeyword, 0, len(*d.keywords(video)))
+		seen := make(map[int]bool)
+		for _, keyword := range *d.keywords(video) {
+			if replacement, exists := replace[keyword.Id]; exists {
+				keyword = replacement
+			}
+			if !seen[keyword.Id] {
+				seen[keyword.Id] = true
+				keywords = append(keywords, keyword)
+			}
+		}
+		*d.keywords(&moved) = keywords
+
+		swap.moved[video] = &moved
+		swap.order = append(swap.order, video)
+	}
+	edb.swapVideos(swap)
+
+	for id, from := range slugs {
+		to := replace[id].GetSlug()
+		if from != to && !slugInUse(d.dict, from) {
+			*d.redirects = addRedirect(*d.redirects, from, to)
+		}
+	}
+
+	for id, keyword := range replace {
+		d.index(id)
+		d.index(keyword.Id)
+	}
+}
+
+/*
+Videos swapped for copies with other tags or models, every other field of
+a copy is the same as the one of its video
+*/
+type entVideoSwap struct {
+	moved map[*EntVideo]*EntVideo
+	order []*EntVideo // Videos of moved in the order of Items
+}
+
+/*
+Put each copy in the place of its video in Items and every index, the
+indexes are rebuilt once for all videos. Caller holds the lock.
+*/
+func (edb *EntDB) swapVideos(swap *entVideoSwap) {
+	if len(swap.order) == 0 {
+		return
+	}
+
+	items := make([]*EntVideo, len(edb.Items))
+	for pos, video := range edb.Items {
+		if moved, exists := swap.moved[video]; exists {
+			video = moved
+		}
+		items[pos] = video
+	}
+	edb.Items = items
+	edb.next.Items = items
+
+	for _, old := range swap.order {
+		video := swap.moved[old]
+
+		edb.DictVideos[video.Id] = video
+		edb.next.dictVideos.sync(edb.DictVideos, video.Id)
+
+		keys := []string{old.GetMD5()}
+		for _, keyword := range old.Keywords {
+			keys = append(keys, keyword.GetMD5())
+		}
+		for _, key := range keys {
+			if edb.Keywords[key] == old {
+				edb.Keywords[key] = video
+				edb.next.keywords.sync(edb.Keywords, key)
+			}
+		}
+
+		edb.next.countFields(old, -1)
+		edb.next.countFields(video, 1)
+	}
+
+	for _, slug := range swap.index(edb.Tags, func(video *EntVideo) []string { return edb.synonyms.tags.slugs(video.Tags) }) {
+		edb.synonyms.tags.link(edb.Tags, slug)
+		for _, member := range edb.synonyms.tags.group(slug) {
+			edb.next.tags.sync(edb.Tags, member)
+		}
+		edb.synonyms.tags.suggest(&edb.next.tagTrie, edb.Tags, slug)
+	}
+	for _, slug := range swap.index(edb.Models, func(video *EntVideo) []string { return edb.synonyms.models.slugs(video.Models) }) {
+		edb.synonyms.models.link(edb.Models, slug)
+		for _, member := range edb.synonyms.models.group(slug) {
+			edb.next.models.sync(edb.Models, member)
+		}
+		edb.synonyms.models.suggest(&edb.next.modelTrie, edb.Models, slug)
+	}
+
+	for _, token := range swap.index(edb.Search, (*EntVideo).GetSearchTokens) {
+		edb.next.search.sync(edb.Search, token)
+	}
+	twoGrams := func(video *EntVideo) []string {
+		grams, _ := video.GetNGrams(false)
+		return grams
+	}
+	for _, gram := range swap.index(edb.TwoGrams, twoGrams) {
+		edb.next.twoGrams.sync(edb.TwoGrams, gram)
+	}
+	threeGrams := func(video *EntVideo) []string {
+		_, grams := video.GetNGrams(false)
+		return grams
+	}
+	for _, gram := range swap.index(edb.ThreeGrams, threeGrams) {
+		edb.next.threeGrams.sync(edb.ThreeGrams, gram)
+	}
+
+	edb.dirty = true
+}
+
+/*
+Rebuild the lists of index holding a moved video with its copy in its place
+and return their keys. keys gives the keys of a video in index: a video
+leaves the lists of keys its copy does not have, the copy is appended to the
+lists of keys only it has.
+*/
+func (s *entVideoSwap) index(index map[string][]*EntVideo, keys func(video *EntVideo) []string) []string {
+	leave := make(map[string]map[*EntVideo]bool)
+	join := make(map[string][]*EntVideo)
+	touched := make([]string, 0)
+	seen := make(map[string]bool)
+
+	touch := func(key string) {
+		if !seen[key] {
+			seen[key] = true
+			touched = append(touched, key)
+		}
+	}
+
+	for _, old := range s.order {
+		video := s.moved[old]
+		oldKeys, newKeys := keys(old), keys(video)
+
+		had := make(map[string]bool, len(oldKeys))
+		for _, key := range oldKeys {
+			had[key] = true
+		}
+		has := make(map[string]bool, len(newKeys))
+		for _, key := range newKeys {
+			has[key] = true
+		}
+
+		for _, key := range oldKeys {
+			touch(key)
+			if !has[key] {
+				if leave[key] == nil {
+					leave[key] = make(map[*EntVideo]bool)
+				}
+				leave[key][old] = true
+			}
+		}
+		for _, key := range newKeys {
+			touch(key)
+			if !had[key] {
+				join[key] = append(join[key], video)
+			}
+		}
+	}
+
+	for _, key := range touched {
+		videos := make([]*EntVideo, 0, len(index[key])+len(join[key]))
+		for _, video := range index[key] {
+			if moved, exists := s.moved[video]; exists {
+				if leave[key][video] {
+					continue
+				}
+				video = moved
+			}
+			videos = append(videos, video)
+		}
+		videos = append(videos, join[key]...)
+
+		if len(videos) == 0 {
+			delete(index, key)
+		} else {
+			index[key] = videos
+		}
+	}
+
+	return touched
+}
+
+func slugInUse(dict map[int]*EntKeyword, slug string) bool {
+	for _, keyword := range dict {
+		if keyword.GetSlug() == slug {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+Decode redirects of a snapshot generation, they replace the current ones
+*/
+func (edb *EntDB) loadRedirects(path string) error {
+	var redirects entRedirects
+	if _, err := DecodeEntFile(path, EntFileRedirects, &redirects); err != nil {
+		return err
+	}
+
+	edb.lock.Lock()
+	defer edb.lock.Unlock()
+
+	edb.redirects = redirects
+	edb.dirty = true
+
+	return nil
+}
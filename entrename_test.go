@@ -0,0 +1,150 @@
+package goentdb
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestEntDBRenameTag(t *testing.T) {
+	entdb := GenerateSynonymsEntDB(t.TempDir())
+
+	if err := entdb.RenameTag(3, "Outdoors"); err != nil {
+		t.Fatalf("test rename tag failed: %v", err)
+	}
+
+	if _, exists := entdb.Tags["outdoor"]; exists {
+		t.Errorf("test rename tag old slug should be gone")
+	}
+	if Got := len(entdb.Index().ByTag("outdoors")); Got != 1 {
+		t.Errorf("test rename tag index failed: got %v, wanted %v", Got, 1)
+	}
+	if video, _ := entdb.GetVideoById(4); video.Tags[0].Phrase != "Outdoors" {
+		t.Errorf("test rename tag video reference failed: got %v, wanted %v", video.Tags[0].Phrase, "Outdoors")
+	}
+	if tag, _ := entdb.GetTagById(3); tag.Phrase != "Outdoors" {
+		t.Errorf("test rename tag dict failed: got %v, wanted %v", tag.Phrase, "Outdoors")
+	}
+	if _, total, _ := entdb.Query("tag:outdoors", 10); total != 1 {
+		t.Errorf("test rename tag query failed: got %v, wanted %v", total, 1)
+	}
+	if Got := entdb.Suggest("outd", 10).Tags; len(Got) != 1 || Got[0].Phrase != "Outdoors" || Got[0].Count != 1 {
+		t.Errorf("test rename tag suggest failed: got %v", Got)
+	}
+
+	if Got, _ := entdb.TagRedirect("outdoor"); Got != "outdoors" {
+		t.Errorf("test rename tag redirect failed: got %v, wanted %v", Got, "outdoors")
+	}
+
+	// Chains are kept one hop long, a slug in use again is no redirect
+	entdb.RenameTag(3, "Outside")
+	if Got, _ := entdb.TagRedirect("outdoor"); Got != "outside" {
+		t.Errorf("test rename tag chain failed: got %v, wanted %v", Got, "outside")
+	}
+	entdb.RenameTag(3, "Outdoor")
+	if Got, exists := entdb.TagRedirect("outdoor"); exists {
+		t.Errorf("test rename tag back should drop the redirect: got %v", Got)
+	}
+	if Got, _ := entdb.TagRedirect("outdoors"); Got != "outdoor" {
+		t.Errorf("test rename tag back failed: got %v, wanted %v", Got, "outdoor")
+	}
+
+	if err := entdb.RenameTag(3, "Blond"); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("test rename tag to existing slug failed: got %v, wanted %v", err, ErrDuplicate)
+	}
+	if err := entdb.RenameTag(10, "Missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("test rename missing tag failed: got %v, wanted %v", err, ErrNotFound)
+	}
+}
+
+func TestEntDBMergeKeywords(t *testing.T) {
+	entdb := GenerateSynonymsEntDB(t.TempDir())
+
+	if err := entdb.MergeTags(1, 2); err != nil {
+		t.Fatalf("test merge tags failed: %v", err)
+	}
+
+	if _, exists := entdb.DictTags[2]; exists {
+		t.Errorf("test merge tags merged tag should be gone")
+	}
+	if Got := len(entdb.Index().ByTag("blonde")); Got != 3 {
+		t.Errorf("test merge tags index failed: got %v, wanted %v", Got, 3)
+	}
+	if _, exists := entdb.Tags["blond"]; exists {
+		t.Errorf("test merge tags old slug should be gone")
+	}
+	// Video with both tags keeps one of them
+	if video, _ := entdb.GetVideoById(3); len(video.Tags) != 1 || video.Tags[0].Id != 1 {
+		t.Errorf("test merge tags video references failed: got %v", video.Tags)
+	}
+	if Got, _ := entdb.TagRedirect("blond"); Got != "blonde" {
+		t.Errorf("test merge tags redirect failed: got %v, wanted %v", Got, "blonde")
+	}
+
+	// Copies take the place of the videos in Items and every index
+	for pos, video := range entdb.Index().Items {
+		if video.Id != uint(pos+1) {
+			t.Errorf("test merge tags order failed: got %v, wanted %v", video.Id, pos+1)
+		}
+	}
+	results, _ := entdb.SearchRanked("funny", 10)
+	if len(results) != 1 || results[0].Video != entdb.DictVideos[2] || results[0].Video.Tags[0].Id != 1 {
+		t.Errorf("test merge tags search index failed: got %v", results)
+	}
+	if Got, _ := entdb.GetVideoByMD5(entdb.DictVideos[2].GetMD5()); Got != entdb.DictVideos[2] {
+		t.Errorf("test merge tags keywords index failed: got %v, wanted %v", Got, entdb.DictVideos[2])
+	}
+
+	if err := entdb.MergeModels(1, 2); err != nil {
+		t.Fatalf("test merge models failed: %v", err)
+	}
+	if Got := len(entdb.Models["jane-doe"]); Got != 2 {
+		t.Errorf("test merge models index failed: got %v, wanted %v", Got, 2)
+	}
+	if Got, _ := entdb.ModelRedirect("janie-doe"); Got != "jane-doe" {
+		t.Errorf("test merge models redirect failed: got %v, wanted %v", Got, "jane-doe")
+	}
+
+	if err := entdb.MergeModels(1, 5); !errors.Is(err, ErrNotFound) {
+		t.Errorf("test merge missing model failed: got %v, wanted %v", err, ErrNotFound)
+	}
+}
+
+func TestEntDBRenamePersist(t *testing.T) {
+	path := t.TempDir()
+	entdb := GenerateWALEntDB(t, path)
+
+	entdb.RenameTag(1, "renamed tag")
+	entdb.MergeTags(1, 3)
+	entdb.RenameModel(1, "renamed model")
+	entdb.CloseWAL()
+
+	check := func(name string, entdb *EntDB) {
+		if len(entdb.DictTags) != 2 || len(entdb.Tags["renamed-tag"]) != 3 || len(entdb.Models["renamed-model"]) != 5 {
+			t.Errorf("test %s indexes failed: got %v/%v/%v, wanted 2/3/5",
+				name, len(entdb.DictTags), len(entdb.Tags["renamed-tag"]), len(entdb.Models["renamed-model"]))
+		}
+		for from, Expected := range map[string]string{"tag-1": "renamed-tag", "tag-3": "renamed-tag"} {
+			if Got, _ := entdb.TagRedirect(from); Got != Expected {
+				t.Errorf("test %s tag redirect %q failed: got %v, wanted %v", name, from, Got, Expected)
+			}
+		}
+		if Got, _ := entdb.ModelRedirect("model-1"); Got != "renamed-model" {
+			t.Errorf("test %s model redirect failed: got %v, wanted %v", name, Got, "renamed-model")
+		}
+	}
+
+	replayed := NewEntDB(path)
+	if err := replayed.Load(); err != nil {
+		t.Fatalf("test rename wal replay failed: %v", err)
+	}
+	check("rename wal replay", replayed)
+
+	if err := replayed.Dump(); err != nil {
+		t.Fatalf("test rename dump failed: %v", err)
+	}
+	loaded := NewEntDB(path)
+	if err := loaded.Load(); err != nil {
+		t.Fatalf("test rename load failed: %v", err)
+	}
+	check("rename snapshot", loaded)
+}
@@ -0,0 +1,316 @@
+package goentdb
+
+import (
+	"html"
+	"math"
+	"sort"
+	"strings"
+)
+
+/*
+BM25F parameters of text search.
+Candidates are the videos with a query token in the title (the Search index),
+the description and keyword fields only add to their score when boosted.
+The zero value ranks with DefaultRanking.
+*/
+type EntRanking struct {
+	K1           float64 // Term frequency saturation
+	B            float64 // Field length normalisation, 0 disables it
+	TitleBoost   float64
+	DescrBoost   float64
+	KeywordBoost float64 // Tag, model and keyword phrases
+	PhraseBoost  float64 // Titles with 2- and 3-word sequences of the query
+}
+
+var DefaultRanking = EntRanking{K1: 1.2, B: 0.75, TitleBoost: 1, PhraseBoost: 1}
+
+func (r EntRanking) orDefault() EntRanking {
+	if r == (EntRanking{}) {
+		return DefaultRanking
+	}
+	return r
+}
+
+type EntSearchResult struct {
+	Video *EntVideo
+	Score float64
+}
+
+type searchField int
+
+const (
+	searchFieldTitle searchField = iota
+	searchFieldDescr
+	searchFieldKeywords
+	searchFields
+)
+
+func (r EntRanking) boost(field searchField) float64 {
+	switch field {
+	case searchFieldTitle:
+		return r.TitleBoost
+	case searchFieldDescr:
+		return r.DescrBoost
+	case searchFieldKeywords:
+		return r.KeywordBoost
+	}
+	return 0
+}
+
+func fieldTokens(video *EntVideo, field searchField) []string {
+	switch field {
+	case searchFieldTitle:
+		return video.GetSearchTokens()
+	case searchFieldDescr:
+		return video.GetDescrTokens()
+	case searchFieldKeywords:
+		return video.GetKeywordTokens()
+	}
+	return nil
+}
+
+/*
+Length and term frequencies of every text search field of a video,
+analyzed once when the video is indexed
+*/
+type entFieldStats struct {
+	length [searchFields]int
+	terms  [searchFields]map[string]int
+}
+
+func newFieldStats(video *EntVideo) *entFieldStats {
+	stats := &entFieldStats{}
+	for field := searchField(0); field < searchFields; field++ {
+		tokens := fieldTokens(video, field)
+		stats.length[field] = len(tokens)
+		stats.terms[field] = make(map[string]int, len(tokens))
+		for _, token := range tokens {
+			stats.terms[field][token]++
+		}
+	}
+	return stats
+}
+
+/*
+Keep the field stats of video and the token totals of every field for the
+average field length, sign is 1 when video is added and -1 when it is removed
+*/
+func (idx *EntIndex) countFields(video *EntVideo, sign int) {
+	stats, exists := idx.fieldStats.get(video.Id)
+	if sign > 0 || !exists {
+		stats = newFieldStats(video)
+	}
+
+	for field := searchField(0); field < searchFields; field++ {
+		idx.fieldTokens[field] += sign * stats.length[field]
+	}
+
+	if sign > 0 {
+		idx.fieldStats.set(video.Id, stats)
+	} else {
+		idx.fieldStats.delete(video.Id)
+	}
+}
+
+/*
+Field stats of video, stored when it was indexed
+*/
+func (idx *EntIndex) videoFieldStats(video *EntVideo) *entFieldStats {
+	if stats, exists := idx.fieldStats.get(video.Id); exists {
+		return stats
+	}
+	return newFieldStats(video)
+}
+
+/*
+Videos matching any of tokens ordered by BM25F score, best first.
+Document frequencies come from the Search index.
+*/
+func (idx *EntIndex) Rank(tokens []string, ranking EntRanking) []EntSearchResult {
+	return sortSearchResults(idx.scoreBM25(tokens, ranking.orDefault()))
+}
+
+/*
+Videos ranked for a parsed query, best first. Words are searched with their synonyms.
+Every phrase of the query must be in the title, the 2- and 3-word
+sequences of the query found in a title add PhraseBoost times their
+inverse frequency to the score.
+*/
+func (idx *EntIndex) Search(query *EntSearchQuery, ranking EntRanking) []EntSearchResult {
+	ranking = ranking.orDefault()
+	scores := idx.scoreBM25(idx.synonyms.expand(query.Words), ranking)
+
+	if len(query.Phrases) > 0 {
+		var required map[*EntVideo]bool
+		for _, phrase := range query.Phrases {
+			matched := make(map[*EntVideo]bool)
+			for _, video := range idx.byPhrase(phrase) {
+				if required == nil || required[video] {
+					matched[video] = true
+				}
+			}
+			required = matched
+		}
+
+		filtered := make(map[*EntVideo]float64, len(required))
+		for video := range required {
+			filtered[video] = scores[video]
+		}
+		scores = filtered
+	}
+
+	idx.boostGrams(scores, query, ranking)
+
+	return sortSearchResults(scores)
+}
+
+/*
+Add the n-gram boost of query to the videos in scores
+*/
+func (idx *EntIndex) boostGrams(scores map[*EntVideo]float64, query *EntSearchQuery, ranking EntRanking) {
+	if ranking.PhraseBoost <= 0 {
+		return
+	}
+
+	total := float64(idx.Len())
+	for _, gram := range query.grams() {
+		var postings []*EntVideo
+		if strings.Count(gram, " ") == 1 {
+			postings = idx.ByTwoGram(gram)
+		} else {
+			postings = idx.ByThreeGram(gram)
+		}
+
+		matched := make(map[*EntVideo]bool)
+		for _, video := range postings {
+			matched[video] = true
+		}
+
+		boost := ranking.PhraseBoost * inverseFrequency(total, float64(len(matched)))
+		for video := range matched {
+			if _, exists := scores[video]; exists {
+				scores[video] += boost
+			}
+		}
+	}
+}
+
+func inverseFrequency(total, df float64) float64 {
+	return math.Log(1 + (total-df+0.5)/(df+0.5))
+}
+
+/*
+BM25F score of every video with one of tokens in the title
+*/
+func (idx *EntIndex) scoreBM25(tokens []string, ranking EntRanking) map[*EntVideo]float64 {
+	total := float64(idx.Len())
+	idf := make(map[string]float64, len(tokens))
+	scores := make(map[*EntVideo]float64)
+
+	for _, token := range tokens {
+		matched := make(map[*EntVideo]bool)
+		for _, video := range idx.BySearch(token) {
+			matched[video] = true
+			scores[video] = 0
+		}
+		if len(matched) == 0 {
+			continue
+		}
+
+		idf[token] = inverseFrequency(total, float64(len(matched)))
+	}
+
+	var average [searchFields]float64
+	for field := range average {
+		if total > 0 {
+			average[field] = float64(idx.fieldTokens[field]) / total
+		}
+	}
+
+	for video := range scores {
+		stats := idx.videoFieldStats(video)
+		// Boosted term frequency, normalised by the length of each field
+		weighted := make(map[string]float64, len(idf))
+
+		for field := searchField(0); field < searchFields; field++ {
+			boost := ranking.boost(field)
+			if boost <= 0 {
+				continue
+			}
+
+			norm := 1.0
+			if average[field] > 0 {
+				norm = 1 - ranking.B + ranking.B*float64(stats.length[field])/average[field]
+			}
+
+			for token := range idf {
+				if tf := stats.terms[field][token]; tf > 0 {
+					weighted[token] += float64(tf) * boost / norm
+				}
+			}
+		}
+
+		score := 0.0
+		for token, tf := range weighted {
+			score += idf[token] * tf * (ranking.K1 + 1) / (ranking.K1 + tf)
+		}
+		scores[video] = score
+	}
+
+	return scores
+}
+
+func sortSearchResults(scores map[*EntVideo]float64) []EntSearchResult {
+	res := make([]EntSearchResult, 0, len(scores))
+	for video, score := range scores {
+		res = append(res, EntSearchResult{Video: video, Score: score})
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Score != res[j].Score {
+			return res[i].Score > res[j].Score
+		}
+		return res[i].Video.Id < res[j].Video.Id
+	})
+
+	return res
+}
+
+/*
+Videos ranked by relevance to Query with their scores, at most Size of them
+and the number of videos which matched. Quoted phrases must be in the title.
+Only videos with a query word in the title match, the description and
+keyword fields re-rank them when boosted but never add matches.
+*/
+func (edb *EntDB) SearchRanked(Query string, Size int) ([]EntSearchResult, int) {
+	idx := edb.Index()
+	res := idx.Search(idx.Analyzer().ParseSearchQuery(Query), edb.Ranking)
+	return res[:Min(len(res), Size)], len(res)
+}
+
+/*
+Videos ranked by relevance to the title of Video, Video itself is excluded
+*/
+func (edb *EntDB) RelevantRanked(Video *EntVideo, Size int) ([]EntSearchResult, int) {
+	idx := edb.Index()
+	title := html.UnescapeString(Video.Title)
+	query := &EntSearchQuery{Words: idx.Analyzer().queryTerms(title), runs: [][]string{phraseTokens(title)}}
+	ranked := idx.Search(query, edb.Ranking)
+
+	res := make([]EntSearchResult, 0, len(ranked))
+	for _, result := range ranked {
+		if result.Video.Id != Video.Id {
+			res = append(res, result)
+		}
+	}
+
+	return res[:Min(len(res), Size)], len(res)
+}
+
+func searchResultVideos(results []EntSearchResult) []*EntVideo {
+	res := make([]*EntVideo, len(results))
+	for i, result := range results {
+		res[i] = result.Video
+	}
+	return res
+}
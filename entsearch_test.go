@@ -0,0 +1,116 @@
+package goentdb
+
+import (
+	"fmt"
+	"testing"
+)
+
+func GenerateSearchEntDB(path string, titles []string) *EntDB {
+	entdb := NewEntDB(path)
+
+	for i, title := range titles {
+		video := NewEntVideo(entdb)
+		video.Id = uint(i + 1)
+		video.Title = title
+		video.Slug = fmt.Sprintf("search-video-%d", i+1)
+		entdb.Add(video)
+	}
+
+	return entdb
+}
+
+func TestEntDBSearchRanked(t *testing.T) {
+	entdb := GenerateSearchEntDB(t.TempDir(), []string{
+		"funny video",
+		"funny cats video",
+		"cats video",
+		"long video about dogs",
+		"dogs video",
+	})
+
+	// "cats" is rarer than "video" so a single cats match outranks the video match
+	results, total := entdb.SearchRanked("cats video", 10)
+
+	Expected := 5
+	if total != Expected {
+		t.Errorf("test search ranked total failed: got %v, wanted %v", total, Expected)
+	}
+
+	// Shorter title of two matching both words wins
+	if results[0].Video.Id != 3 || results[1].Video.Id != 2 {
+		t.Errorf("test search ranked order failed: got %v %v, wanted %v %v", results[0].Video.Id, results[1].Video.Id, 3, 2)
+	}
+	for i := 1; i < len(results); i++ {
+		if results[i].Score > results[i-1].Score {
+			t.Errorf("test search ranked scores failed: %v before %v", results[i-1].Score, results[i].Score)
+		}
+	}
+	if results[4].Score <= 0 {
+		t.Errorf("test search ranked score failed: got %v, wanted > 0", results[4].Score)
+	}
+
+	videos, _ := entdb.RandomSetBySearch("cats", 1)
+	if len(videos) != 1 || videos[0].Id != 3 {
+		t.Errorf("test random set by search failed: got %v", videos)
+	}
+
+	relevant, total := entdb.GetRelevantForVideoBySearch(entdb.DictVideos[2], 10)
+	if total != 4 {
+		t.Errorf("test relevant by search failed: got %v, wanted %v", total, 4)
+	}
+	for _, video := range relevant {
+		if video.Id == 2 {
+			t.Errorf("test relevant by search should exclude the video itself")
+		}
+	}
+}
+
+func TestEntDBSearchBoosts(t *testing.T) {
+	entdb := GenerateSearchEntDB(t.TempDir(), []string{"red car"})
+
+	video := NewEntVideo(entdb)
+	video.Id = uint(2)
+	video.Title = "red bike"
+	video.Slug = "red-bike"
+	video.Descr = "the bike is parked next to a red car"
+	entdb.Add(video)
+
+	results, _ := entdb.SearchRanked("car", 10)
+	if len(results) != 1 {
+		t.Errorf("test search without descr boost failed: got %v, wanted %v", len(results), 1)
+	}
+
+	// Descriptions only add to the score of videos matched by title
+	entdb.Ranking = EntRanking{K1: 1.2, B: 0.75, TitleBoost: 1, DescrBoost: 2}
+	results, _ = entdb.SearchRanked("red", 10)
+	if results[0].Video.Id != 2 {
+		t.Errorf("test search descr boost failed: got %v, wanted %v", results[0].Video.Id, 2)
+	}
+}
+
+func TestEntIndexFieldTokens(t *testing.T) {
+	entdb := GenerateSearchEntDB(t.TempDir(), []string{"red car", "blue big bike"})
+
+	Expected := 5
+	Got := entdb.Index().fieldTokens[searchFieldTitle]
+	if Got != Expected {
+		t.Errorf("test field tokens failed: got %v, wanted %v", Got, Expected)
+	}
+
+	entdb.Remove(uint(2))
+
+	Expected = 2
+	Got = entdb.Index().fieldTokens[searchFieldTitle]
+	if Got != Expected {
+		t.Errorf("test field tokens after remove failed: got %v, wanted %v", Got, Expected)
+	}
+
+	// Field stats are kept per video, not analyzed again at query time
+	stats, exists := entdb.Index().fieldStats.get(1)
+	if !exists || stats.length[searchFieldTitle] != 2 || stats.terms[searchFieldTitle]["car"] != 1 {
+		t.Errorf("test field stats failed: got %v", stats)
+	}
+	if _, exists := entdb.Index().fieldStats.get(2); exists {
+		t.Errorf("test field stats after remove should be gone")
+	}
+}
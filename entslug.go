@@ -2,10 +2,16 @@ package goentdb
 
 import (
 	"strings"
+
+	"golang.org/x/text/unicode/norm"
 )
 
 type EntSlug string
 
+/*
+Slug of a tag or model phrase, NFKC normalized so that compatibility forms
+of a phrase share the slug
+*/
 func (s EntSlug) GetSlug() string {
-	return strings.Replace(strings.Replace(strings.ToLower(string(s)), " ", "-", -1), "#", "", -1)
+	return strings.Replace(strings.Replace(strings.ToLower(norm.NFKC.String(string(s))), " ", "-", -1), "#", "", -1)
 }
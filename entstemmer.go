@@ -0,0 +1,233 @@
+package goentdb
+
+import (
+	"strings"
+)
+
+/*
+Reduces a search term to its stem, so that "girls" finds "girl"
+*/
+type EntStemmer interface {
+	Stem(term string) string
+}
+
+/*
+English stemmer of M.F. Porter, "An algorithm for suffix stripping" (1980),
+with the bli and logi rules of the reference implementation.
+Terms with anything but a-z are kept as they are.
+*/
+type PorterStemmer struct{}
+
+func (PorterStemmer) Stem(term string) string {
+	if len(term) <= 2 {
+		return term
+	}
+	for i := 0; i < len(term); i++ {
+		if term[i] < 'a' || term[i] > 'z' {
+			return term
+		}
+	}
+
+	w := porterWord(term)
+	w = w.step1a()
+	w = w.step1b()
+	w = w.step1c()
+	w = w.replace(porterStep2, 0)
+	w = w.replace(porterStep3, 0)
+	w = w.step4()
+	w = w.step5()
+
+	return string(w)
+}
+
+type porterWord []byte
+
+func (w porterWord) consonant(i int) bool {
+	switch w[i] {
+	case 'a', 'e', 'i', 'o', 'u':
+		return false
+	case 'y':
+		return i == 0 || !w.consonant(i-1)
+	}
+	return true
+}
+
+/*
+Number of vowel-consonant sequences, m in [C](VC){m}[V]
+*/
+func (w porterWord) measure() int {
+	m := 0
+	i := 0
+	for i < len(w) && w.consonant(i) {
+		i++
+	}
+	for i < len(w) {
+		for i < len(w) && !w.consonant(i) {
+			i++
+		}
+		if i == len(w) {
+			break
+		}
+		m++
+		for i < len(w) && w.consonant(i) {
+			i++
+		}
+	}
+	return m
+}
+
+func (w porterWord) hasVowel() bool {
+	for i := range w {
+		if !w.consonant(i) {
+			return true
+		}
+	}
+	return false
+}
+
+func (w porterWord) doubleConsonant() bool {
+	n := len(w)
+	return n >= 2 && w[n-1] == w[n-2] && w.consonant(n-1)
+}
+
+/*
+Ends consonant-vowel-consonant and the last consonant is not w, x or y
+*/
+func (w porterWord) cvc() bool {
+	n := len(w)
+	if n < 3 || !w.consonant(n-3) || w.consonant(n-2) || !w.consonant(n-1) {
+		return false
+	}
+	return w[n-1] != 'w' && w[n-1] != 'x' && w[n-1] != 'y'
+}
+
+func (w porterWord) hasSuffix(suffix string) bool {
+	return strings.HasSuffix(string(w), suffix)
+}
+
+func (w porterWord) stem(suffix string) porterWord {
+	return w[:len(w)-len(suffix)]
+}
+
+func (w porterWord) with(suffix string) porterWord {
+	return append(append(porterWord{}, w...), suffix...)
+}
+
+func (w porterWord) step1a() porterWord {
+	switch {
+	case w.hasSuffix("sses"), w.hasSuffix("ies"):
+		return w[:len(w)-2]
+	case w.hasSuffix("ss"):
+		return w
+	case w.hasSuffix("s"):
+		return w[:len(w)-1]
+	}
+	return w
+}
+
+func (w porterWord) step1b() porterWord {
+	if w.hasSuffix("eed") {
+		if w.stem("eed").measure() > 0 {
+			return w[:len(w)-1]
+		}
+		return w
+	}
+
+	var stem porterWord
+	switch {
+	case w.hasSuffix("ed") && w.stem("ed").hasVowel():
+		stem = w.stem("ed")
+	case w.hasSuffix("ing") && w.stem("ing").hasVowel():
+		stem = w.stem("ing")
+	default:
+		return w
+	}
+
+	switch {
+	case stem.hasSuffix("at"), stem.hasSuffix("bl"), stem.hasSuffix("iz"):
+		return stem.with("e")
+	case stem.doubleConsonant() && !stem.hasSuffix("l") && !stem.hasSuffix("s") && !stem.hasSuffix("z"):
+		return stem[:len(stem)-1]
+	case stem.measure() == 1 && stem.cvc():
+		return stem.with("e")
+	}
+	return stem
+}
+
+func (w porterWord) step1c() porterWord {
+	if w.hasSuffix("y") && w.stem("y").hasVowel() {
+		return w.stem("y").with("i")
+	}
+	return w
+}
+
+type porterRule struct {
+	suffix      string
+	replacement string
+}
+
+// Longer suffixes come before the suffixes they end with
+var porterStep2 = []porterRule{
+	{"ational", "ate"}, {"tional", "tion"}, {"enci", "ence"}, {"anci", "ance"}, {"izer", "ize"},
+	{"bli", "ble"}, {"alli", "al"}, {"entli", "ent"}, {"eli", "e"}, {"ousli", "ous"},
+	{"ization", "ize"}, {"ation", "ate"}, {"ator", "ate"}, {"alism", "al"}, {"iveness", "ive"},
+	{"fulness", "ful"}, {"ousness", "ous"}, {"aliti", "al"}, {"iviti", "ive"}, {"biliti", "ble"},
+	{"logi", "log"},
+}
+
+var porterStep3 = []porterRule{
+	{"icate", "ic"}, {"ative", ""}, {"alize", "al"}, {"iciti", "ic"}, {"ical", "ic"}, {"ful", ""}, {"ness", ""},
+}
+
+var porterStep4 = []string{
+	"al", "ance", "ence", "er", "ic", "able", "ible", "ant", "ement", "ment", "ent",
+	"ion", "ou", "ism", "ate", "iti", "ous", "ive", "ize",
+}
+
+/*
+Replace the first of rules the word ends with when its stem measures more than m
+*/
+func (w porterWord) replace(rules []porterRule, m int) porterWord {
+	for _, rule := range rules {
+		if w.hasSuffix(rule.suffix) {
+			if stem := w.stem(rule.suffix); stem.measure() > m {
+				return stem.with(rule.replacement)
+			}
+			return w
+		}
+	}
+	return w
+}
+
+func (w porterWord) step4() porterWord {
+	for _, suffix := range porterStep4 {
+		if !w.hasSuffix(suffix) {
+			continue
+		}
+
+		stem := w.stem(suffix)
+		if suffix == "ion" && !stem.hasSuffix("s") && !stem.hasSuffix("t") {
+			return w
+		}
+		if stem.measure() > 1 {
+			return stem
+		}
+		return w
+	}
+	return w
+}
+
+func (w porterWord) step5() porterWord {
+	if w.hasSuffix("e") {
+		stem := w.stem("e")
+		if m := stem.measure(); m > 1 || (m == 1 && !stem.cvc()) {
+			w = stem
+		}
+	}
+
+	if w.measure() > 1 && w.doubleConsonant() && w.hasSuffix("l") {
+		w = w[:len(w)-1]
+	}
+
+	return w
+}
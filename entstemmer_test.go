@@ -0,0 +1,47 @@
+package goentdb
+
+import (
+	"testing"
+)
+
+func TestPorterStemmer(t *testing.T) {
+	cases := map[string]string{
+		"caresses":       "caress",
+		"ponies":         "poni",
+		"cats":           "cat",
+		"girls":          "girl",
+		"feed":           "feed",
+		"agreed":         "agre",
+		"plastered":      "plaster",
+		"motoring":       "motor",
+		"sing":           "sing",
+		"conflated":      "conflat",
+		"troubled":       "troubl",
+		"sized":          "size",
+		"hopping":        "hop",
+		"falling":        "fall",
+		"filing":         "file",
+		"happy":          "happi",
+		"relational":     "relat",
+		"conditional":    "condit",
+		"rational":       "ration",
+		"generalization": "gener",
+		"hopeful":        "hope",
+		"goodness":       "good",
+		"adjustment":     "adjust",
+		"controlling":    "control",
+		"roll":           "roll",
+		"running":        "run",
+		"runs":           "run",
+		"blonde":         "blond",
+		"is":             "is",
+		"schöne":         "schöne",
+		"mp4":            "mp4",
+	}
+
+	for term, Expected := range cases {
+		if Got := (PorterStemmer{}).Stem(term); Got != Expected {
+			t.Errorf("test porter stem %q failed: got %v, wanted %v", term, Got, Expected)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package goentdb
+
+import (
+	"strings"
+)
+
+type EntSuggestions struct {
+	Tags   []EntSuggestion
+	Models []EntSuggestion
+	Tokens []EntSuggestion // Completions of the last word of the prefix
+}
+
+/*
+Tags starting with prefix, case-insensitive, the ones on most videos first
+*/
+func (idx *EntIndex) SuggestTags(prefix string, Size int) []EntSuggestion {
+	return idx.tagTrie.top(suggestKey(prefix), Size)
+}
+
+/*
+Models starting with prefix, case-insensitive, the ones on most videos first
+*/
+func (idx *EntIndex) SuggestModels(prefix string, Size int) []EntSuggestion {
+	return idx.modelTrie.top(suggestKey(prefix), Size)
+}
+
+/*
+Title tokens starting with prefix, the ones in most titles first
+*/
+func (idx *EntIndex) SuggestTokens(prefix string, Size int) []EntSuggestion {
+	return idx.tokenTrie.top(suggestKey(prefix), Size)
+}
+
+/*
+Type-ahead suggestions for prefix, up to Size of each kind
+*/
+func (edb *EntDB) Suggest(prefix string, Size int) *EntSuggestions {
+	idx := edb.Index()
+
+	words := strings.Fields(prefix)
+	last := ""
+	if len(words) > 0 && !strings.HasSuffix(prefix, " ") {
+		last = words[len(words)-1]
+	}
+
+	res := &EntSuggestions{
+		Tags:   idx.SuggestTags(prefix, Size),
+		Models: idx.SuggestModels(prefix, Size),
+		Tokens: make([]EntSuggestion, 0),
+	}
+	if last != "" {
+		res.Tokens = idx.SuggestTokens(last, Size)
+	}
+
+	return res
+}
+
+/*
+Keep the suggestion of a keyword in sync with its dictionary and its index,
+called with the lock held like indexVideo
+*/
+func (edb *EntDB) suggestTag(id int) {
+	suggestKeyword(&edb.next.tagTrie, edb.DictTags[id], edb.Tags)
+}
+
+func (edb *EntDB) suggestModel(id int) {
+	suggestKeyword(&edb.next.modelTrie, edb.DictModels[id], edb.Models)
+}
+
+func suggestKeyword(trie *entTrie, keyword *EntKeyword, index map[string][]*EntVideo) {
+	if keyword == nil {
+		return
+	}
+
+	trie.set(suggestKey(keyword.Phrase), &EntSuggestion{
+		Phrase:  keyword.Phrase,
+		Keyword: keyword,
+		Count:   len(index[keyword.GetSlug()]),
+	})
+}
+
+/*
+Count the title words of video in the token suggestions, sign is 1 when
+video is added and -1 when it is removed. Words are suggested as they are
+typed rather than as their stems, which are the keys of the Search index.
+*/
+func (edb *EntDB) suggestTokens(video *EntVideo, sign int) {
+	seen := make(map[string]bool)
+
+	video.analyzer().analyze(video.GetTitle(), func(token string, term string) {
+		if seen[token] {
+			return
+		}
+		seen[token] = true
+
+		count := sign
+		if node := edb.next.tokenTrie.find(token); node != nil && node.term != nil {
+			count += node.term.Count
+		}
+
+		if count <= 0 {
+			edb.next.tokenTrie.set(token, nil)
+			return
+		}
+		edb.next.tokenTrie.set(token, &EntSuggestion{Phrase: token, Count: count})
+	})
+}
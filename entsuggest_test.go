@@ -0,0 +1,140 @@
+package goentdb
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestEntTrie(t *testing.T) {
+	trie := newTrie()
+	master := make(map[string]int)
+
+	keys := make([]string, 0)
+	for i := 0; i < 300; i++ {
+		keys = append(keys, fmt.Sprintf("%x", rand.Intn(4096)))
+	}
+
+	var frozen []entTrie
+	var frozenMasters []map[string]int
+
+	for i := 0; i < 3000; i++ {
+		key := keys[rand.Intn(len(keys))]
+		if rand.Intn(3) == 0 {
+			delete(master, key)
+			trie.set(key, nil)
+		} else {
+			master[key] = rand.Intn(50)
+			trie.set(key, &EntSuggestion{Phrase: key, Count: master[key]})
+		}
+
+		if i%500 == 0 {
+			copied := make(map[string]int, len(master))
+			for k, v := range master {
+				copied[k] = v
+			}
+			frozen = append(frozen, trie)
+			frozenMasters = append(frozenMasters, copied)
+			trie.freeze()
+		}
+	}
+
+	frozen = append(frozen, trie)
+	frozenMasters = append(frozenMasters, master)
+
+	// Every published version still suggests exactly what it held when it was published
+	for pos, version := range frozen {
+		if version.len() != len(frozenMasters[pos]) {
+			t.Errorf("test trie len failed: got %v, wanted %v", version.len(), len(frozenMasters[pos]))
+		}
+
+		for _, prefix := range []string{"", "a", "1", "f0"} {
+			Expected := make([]EntSuggestion, 0)
+			for key, count := range frozenMasters[pos] {
+				if strings.HasPrefix(key, prefix) {
+					Expected = append(Expected, EntSuggestion{Phrase: key, Count: count})
+				}
+			}
+			sort.Slice(Expected, func(i, j int) bool {
+				if Expected[i].Count != Expected[j].Count {
+					return Expected[i].Count > Expected[j].Count
+				}
+				return Expected[i].Phrase < Expected[j].Phrase
+			})
+			Expected = Expected[:Min(len(Expected), 10)]
+
+			Got := version.top(prefix, 10)
+			if len(Got) != len(Expected) {
+				t.Errorf("test trie top %q failed: got %v, wanted %v", prefix, Got, Expected)
+				continue
+			}
+			for i := range Got {
+				if Got[i] != Expected[i] {
+					t.Errorf("test trie top %q failed: got %v, wanted %v", prefix, Got[i], Expected[i])
+				}
+			}
+		}
+	}
+}
+
+func TestEntDBSuggest(t *testing.T) {
+	entdb := NewEntDB(t.TempDir())
+	entdb.AddTag(NewTag(1, "Blonde"))
+	entdb.AddTag(NewTag(2, "Blowjob"))
+	entdb.AddTag(NewTag(3, "Bloopers"))
+	entdb.AddModel(NewModel(1, "Jane Doe"))
+
+	for i := 1; i <= 5; i++ {
+		video := NewEntVideo(entdb)
+		video.Id = uint(i)
+		video.Title = fmt.Sprintf("blonde number %d", i)
+		if i > 3 {
+			video.Title = fmt.Sprintf("blowing number %d", i)
+		}
+		video.Slug = EntSlug(video.Title).GetSlug()
+		video.AddTag(entdb.DictTags[1+i%2])
+		video.AddModel(entdb.DictModels[1])
+		entdb.Add(video)
+	}
+
+	suggestions := entdb.Suggest("Blo", 10)
+
+	ExpectedTags := []EntSuggestion{{"Blowjob", entdb.DictTags[2], 3}, {"Blonde", entdb.DictTags[1], 2}, {"Bloopers", entdb.DictTags[3], 0}}
+	if len(suggestions.Tags) != len(ExpectedTags) {
+		t.Fatalf("test suggest tags failed: got %v, wanted %v", suggestions.Tags, ExpectedTags)
+	}
+	for i, Expected := range ExpectedTags {
+		if suggestions.Tags[i] != Expected {
+			t.Errorf("test suggest tags failed: got %v, wanted %v", suggestions.Tags[i], Expected)
+		}
+	}
+
+	ExpectedTokens := []EntSuggestion{{"blonde", nil, 3}, {"blowing", nil, 2}}
+	if len(suggestions.Tokens) != len(ExpectedTokens) {
+		t.Fatalf("test suggest tokens failed: got %v, wanted %v", suggestions.Tokens, ExpectedTokens)
+	}
+	for i, Expected := range ExpectedTokens {
+		if suggestions.Tokens[i] != Expected {
+			t.Errorf("test suggest tokens failed: got %v, wanted %v", suggestions.Tokens[i], Expected)
+		}
+	}
+
+	if len(entdb.Suggest("jane d", 10).Models) != 1 {
+		t.Errorf("test suggest models failed: got %v", entdb.Suggest("jane d", 10).Models)
+	}
+	if len(entdb.Suggest("jane d", 10).Tokens) != 0 {
+		t.Errorf("test suggest tokens of the last word failed: got %v", entdb.Suggest("jane d", 10).Tokens)
+	}
+
+	// Removed videos no longer count
+	entdb.Remove(uint(4))
+	entdb.Remove(uint(5))
+	if Got := entdb.Suggest("blow", 10).Tokens; len(Got) != 0 {
+		t.Errorf("test suggest after remove failed: got %v", Got)
+	}
+	if Got := entdb.Suggest("blow", 10).Tags; Got[0].Count != 2 {
+		t.Errorf("test suggest tag count after remove failed: got %v, wanted %v", Got[0].Count, 2)
+	}
+}
@@ -0,0 +1,352 @@
+package goentdb
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+/*
+Synonyms of search words and aliases of tags and models, see EntDB.SetSynonyms.
+A query word is searched as every word of its groups too. The videos of a tag
+or model alias are indexed under the slug of its canonical phrase, Tags and
+Models hold the same video list under the slug of every alias.
+*/
+type EntSynonyms struct {
+	words  [][]string
+	tags   [][]string // Canonical phrase first
+	models [][]string
+}
+
+var errSynonymGroup = errors.New("a group needs at least 2 comma separated phrases")
+
+func NewEntSynonyms() *EntSynonyms {
+	return &EntSynonyms{}
+}
+
+/*
+Words searched for each other
+*/
+func (s *EntSynonyms) AddWords(words ...string) {
+	if len(words) > 1 {
+		s.words = append(s.words, words)
+	}
+}
+
+func (s *EntSynonyms) AddTagAliases(canonical string, aliases ...string) {
+	s.tags = append(s.tags, append([]string{canonical}, aliases...))
+}
+
+func (s *EntSynonyms) AddModelAliases(canonical string, aliases ...string) {
+	s.models = append(s.models, append([]string{canonical}, aliases...))
+}
+
+/*
+Read synonyms from a text file with a group per line:
+
+	blond, blonde, blondie
+	tag: Blonde, Blond, Blondie
+	model: Jane Doe, Janie Doe
+
+Plain lines are words searched for each other, the first phrase of a tag or
+model line is the canonical one and the others are its aliases.
+Empty lines and lines starting with # are skipped.
+*/
+func LoadEntSynonyms(path string) (*EntSynonyms, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	res := NewEntSynonyms()
+	scanner := bufio.NewScanner(f)
+
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		kind := ""
+		for _, prefix := range []string{"tag:", "model:"} {
+			if len(text) >= len(prefix) && strings.EqualFold(text[:len(prefix)], prefix) {
+				kind, text = prefix, text[len(prefix):]
+			}
+		}
+
+		phrases := make([]string, 0)
+		for _, phrase := range strings.Split(text, ",") {
+			if phrase = strings.TrimSpace(phrase); phrase != "" {
+				phrases = append(phrases, phrase)
+			}
+		}
+		if len(phrases) < 2 {
+			return nil, fmt.Errorf("synonyms %s: %w", path, EntLineError{Line: line, Err: errSynonymGroup})
+		}
+
+		switch kind {
+		case "tag:":
+			res.AddTagAliases(phrases[0], phrases[1:]...)
+		case "model:":
+			res.AddModelAliases(phrases[0], phrases[1:]...)
+		default:
+			res.AddWords(phrases...)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+/*
+Synonyms as the indexes use them, the zero value has none
+*/
+type entSynonyms struct {
+	words  map[string][]string // Search term -> every term searched with it, itself included
+	tags   entAliases
+	models entAliases
+}
+
+type entAliases struct {
+	canonical map[string]string   // Alias slug -> canonical slug
+	aliases   map[string][]string // Canonical slug -> alias slugs
+	phrases   map[string]string   // Slug -> phrase of the group, finds the keywords of a group
+}
+
+func (s *EntSynonyms) compile(analyzer *EntAnalyzer) entSynonyms {
+	res := entSynonyms{
+		words:  make(map[string][]string),
+		tags:   newAliases(s.tags),
+		models: newAliases(s.models),
+	}
+
+	for _, group := range s.words {
+		terms := make([]string, 0)
+		for _, word := range group {
+			terms = appendDistinct(terms, analyzer.Terms(word)...)
+		}
+		for _, term := range terms {
+			res.words[term] = appendDistinct(res.words[term], terms...)
+		}
+	}
+
+	return res
+}
+
+func newAliases(groups [][]string) entAliases {
+	res := entAliases{
+		canonical: make(map[string]string),
+		aliases:   make(map[string][]string),
+		phrases:   make(map[string]string),
+	}
+
+	for _, group := range groups {
+		slug := EntSlug(group[0]).GetSlug()
+		res.phrases[slug] = group[0]
+
+		for _, alias := range group[1:] {
+			aliasSlug := EntSlug(alias).GetSlug()
+			if _, exists := res.canonical[aliasSlug]; exists || aliasSlug == slug {
+				continue
+			}
+			res.canonical[aliasSlug] = slug
+			res.aliases[slug] = append(res.aliases[slug], aliasSlug)
+			res.phrases[aliasSlug] = alias
+		}
+	}
+
+	return res
+}
+
+func appendDistinct(values []string, more ...string) []string {
+	for _, value := range more {
+		found := false
+		for _, existing := range values {
+			if existing == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+/*
+Terms with every synonym of each of them
+*/
+func (s entSynonyms) expand(terms []string) []string {
+	if len(s.words) == 0 {
+		return terms
+	}
+
+	res := make([]string, 0, len(terms))
+	for _, term := range terms {
+		if group, exists := s.words[term]; exists {
+			res = appendDistinct(res, group...)
+		} else {
+			res = appendDistinct(res, term)
+		}
+	}
+	return res
+}
+
+/*
+Canonical slug of slug, slug itself when it is no alias
+*/
+func (a entAliases) slug(slug string) string {
+	if canonical, exists := a.canonical[slug]; exists {
+		return canonical
+	}
+	return slug
+}
+
+/*
+Distinct canonical slugs of keywords
+*/
+func (a entAliases) slugs(keywords []*EntKeyword) []string {
+	res := make([]string, 0, len(keywords))
+	for _, keyword := range keywords {
+		res = appendDistinct(res, a.slug(keyword.GetSlug()))
+	}
+	return res
+}
+
+/*
+Point the aliases of slug at its videos in index. The aliases share the
+slice of slug instead of a copy, so only add and remove may change it, always
+through the canonical slug and followed by link again: an append through an
+alias would write into the canonical slice behind its length.
+*/
+func (a entAliases) link(index map[string][]*EntVideo, slug string) {
+	for _, alias := range a.aliases[slug] {
+		if videos, exists := index[slug]; exists {
+			index[alias] = videos
+		} else {
+			delete(index, alias)
+		}
+	}
+}
+
+/*
+Slug and alias slugs of the group of slug
+*/
+func (a entAliases) group(slug string) []string {
+	return append([]string{slug}, a.aliases[slug]...)
+}
+
+/*
+Keyword of the canonical phrase of keyword, found through the suggestions
+of the dictionary. keyword itself when it is no alias or the canonical
+phrase is not in the dictionary.
+*/
+func (a entAliases) keyword(trie *entTrie, keyword *EntKeyword) *EntKeyword {
+	slug, exists := a.canonical[keyword.GetSlug()]
+	if !exists {
+		return keyword
+	}
+	if node := trie.find(suggestKey(a.phrases[slug])); node != nil && node.term != nil && node.term.Keyword != nil {
+		return node.term.Keyword
+	}
+	return keyword
+}
+
+/*
+Keep the suggestions of every keyword of the group of slug in sync,
+an alias counts the videos of the whole group
+*/
+func (a entAliases) suggest(trie *entTrie, index map[string][]*EntVideo, slug string) {
+	if len(a.aliases[slug]) == 0 {
+		return
+	}
+	for _, member := range a.group(slug) {
+		if node := trie.find(suggestKey(a.phrases[member])); node != nil && node.term != nil {
+			suggestKeyword(trie, node.term.Keyword, index)
+		}
+	}
+}
+
+/*
+Tag with the canonical phrase of tag, tag itself when it is no alias
+*/
+func (idx *EntIndex) CanonicalTag(tag *EntKeyword) *EntKeyword {
+	return idx.synonyms.tags.keyword(&idx.tagTrie, tag)
+}
+
+/*
+Model with the canonical phrase of model, model itself when it is no alias
+*/
+func (idx *EntIndex) CanonicalModel(model *EntKeyword) *EntKeyword {
+	return idx.synonyms.models.keyword(&idx.modelTrie, model)
+}
+
+/*
+Use synonyms for search and the tag and model indexes, nil drops them.
+Words are analyzed with the analyzer of the EntDB, so Language has to be
+set before. The videos of the tags and models are indexed again.
+*/
+func (edb *EntDB) SetSynonyms(synonyms *EntSynonyms) {
+	if synonyms == nil {
+		synonyms = NewEntSynonyms()
+	}
+	compiled := synonyms.compile(edb.Analyzer())
+
+	edb.lock.Lock()
+	defer edb.lock.Unlock()
+	defer edb.publish()
+
+	edb.synonyms = compiled
+	edb.Tags = edb.reindexKeywords(edb.Tags, &edb.next.tags, func(video *EntVideo) []*EntKeyword { return video.Tags }, compiled.tags)
+	edb.Models = edb.reindexKeywords(edb.Models, &edb.next.models, func(video *EntVideo) []*EntKeyword { return video.Models }, compiled.models)
+
+	for id := range edb.DictTags {
+		edb.suggestTag(id)
+	}
+	for id := range edb.DictModels {
+		edb.suggestModel(id)
+	}
+
+	edb.dirty = true
+}
+
+/*
+SetSynonyms with the synonyms of the file at path, see LoadEntSynonyms
+*/
+func (edb *EntDB) LoadSynonyms(path string) error {
+	synonyms, err := LoadEntSynonyms(path)
+	if err != nil {
+		return err
+	}
+
+	edb.SetSynonyms(synonyms)
+	return nil
+}
+
+func (edb *EntDB) reindexKeywords(old map[string][]*EntVideo, next *entHAMT[string, []*EntVideo], keywords func(video *EntVideo) []*EntKeyword, aliases entAliases) map[string][]*EntVideo {
+	index := make(map[string][]*EntVideo, len(old))
+	for _, video := range edb.Items {
+		for _, slug := range aliases.slugs(keywords(video)) {
+			index[slug] = append(index[slug], video)
+		}
+	}
+	for slug := range aliases.aliases {
+		aliases.link(index, slug)
+	}
+
+	for slug := range old {
+		next.sync(index, slug)
+	}
+	for slug := range index {
+		next.sync(index, slug)
+	}
+
+	return index
+}
@@ -0,0 +1,144 @@
+package goentdb
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func GenerateSynonymsEntDB(path string) *EntDB {
+	entdb := NewEntDB(path)
+	entdb.AddTag(NewTag(1, "Blonde"))
+	entdb.AddTag(NewTag(2, "Blond"))
+	entdb.AddTag(NewTag(3, "Outdoor"))
+	entdb.AddModel(NewModel(1, "Jane Doe"))
+	entdb.AddModel(NewModel(2, "Janie Doe"))
+
+	videos := []struct {
+		Title  string
+		Tags   []int
+		Models []int
+	}{
+		{Title: "cute kitten", Tags: []int{1}, Models: []int{1}},
+		{Title: "funny cat", Tags: []int{2}, Models: []int{2}},
+		{Title: "sleepy cat on the sofa", Tags: []int{1, 2}},
+		{Title: "dog in the park", Tags: []int{3}},
+	}
+
+	for i, v := range videos {
+		video := NewEntVideo(entdb)
+		video.Id = uint(i + 1)
+		video.Title = v.Title
+		video.Slug = video.GetSlug()
+		for _, id := range v.Tags {
+			video.AddTag(entdb.DictTags[id])
+		}
+		for _, id := range v.Models {
+			video.AddModel(entdb.DictModels[id])
+		}
+		entdb.Add(video)
+	}
+
+	return entdb
+}
+
+func TestLoadEntSynonyms(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "synonyms.txt")
+	os.WriteFile(path, []byte("# pets\ncat, kitten, kitty\n\nTag: Blonde, Blond\nmodel: Jane Doe, Janie Doe\n"), 0644)
+
+	synonyms, err := LoadEntSynonyms(path)
+	if err != nil {
+		t.Fatalf("test load synonyms failed: %v", err)
+	}
+	if len(synonyms.words) != 1 || len(synonyms.words[0]) != 3 {
+		t.Errorf("test load synonym words failed: got %v", synonyms.words)
+	}
+	if len(synonyms.tags) != 1 || synonyms.tags[0][0] != "Blonde" || synonyms.tags[0][1] != "Blond" {
+		t.Errorf("test load tag aliases failed: got %v", synonyms.tags)
+	}
+	if len(synonyms.models) != 1 || synonyms.models[0][1] != "Janie Doe" {
+		t.Errorf("test load model aliases failed: got %v", synonyms.models)
+	}
+
+	os.WriteFile(path, []byte("cat, kitten\ntag: Blonde\n"), 0644)
+
+	var lineErr EntLineError
+	if _, err := LoadEntSynonyms(path); !errors.As(err, &lineErr) || lineErr.Line != 2 {
+		t.Errorf("test load synonyms bad line failed: got %v, wanted line %v", err, 2)
+	}
+}
+
+func TestEntDBSynonyms(t *testing.T) {
+	entdb := GenerateSynonymsEntDB(t.TempDir())
+
+	if _, total := entdb.SearchRanked("cat", 10); total != 2 {
+		t.Errorf("test search without synonyms failed: got %v, wanted %v", total, 2)
+	}
+
+	synonyms := NewEntSynonyms()
+	synonyms.AddWords("cat", "kitten")
+	synonyms.AddTagAliases("Blonde", "Blond")
+	synonyms.AddModelAliases("Jane Doe", "Janie Doe")
+	entdb.SetSynonyms(synonyms)
+
+	if _, total := entdb.SearchRanked("cat", 10); total != 3 {
+		t.Errorf("test search synonyms failed: got %v, wanted %v", total, 3)
+	}
+	if _, total, _ := entdb.Query("kittens -tag:outdoor", 10); total != 3 {
+		t.Errorf("test query synonyms failed: got %v, wanted %v", total, 3)
+	}
+
+	// Every alias resolves to the same video list
+	for _, slug := range []string{"blonde", "blond"} {
+		if Got := len(entdb.Tags[slug]); Got != 3 {
+			t.Errorf("test tag alias %q failed: got %v, wanted %v", slug, Got, 3)
+		}
+		if Got := len(entdb.Index().ByTag(slug)); Got != 3 {
+			t.Errorf("test index tag alias %q failed: got %v, wanted %v", slug, Got, 3)
+		}
+		if _, total, _ := entdb.Query("tag:"+slug, 10); total != 3 {
+			t.Errorf("test query tag alias %q failed: got %v, wanted %v", slug, total, 3)
+		}
+	}
+	if Got := len(entdb.Models["janie-doe"]); Got != 2 {
+		t.Errorf("test model alias failed: got %v, wanted %v", Got, 2)
+	}
+
+	idx := entdb.Index()
+	if Got := idx.CanonicalTag(entdb.DictTags[2]); Got != entdb.DictTags[1] {
+		t.Errorf("test canonical tag failed: got %v, wanted %v", Got, entdb.DictTags[1])
+	}
+	if Got := idx.CanonicalTag(entdb.DictTags[3]); Got != entdb.DictTags[3] {
+		t.Errorf("test canonical of no alias failed: got %v, wanted %v", Got, entdb.DictTags[3])
+	}
+	if Got := idx.CanonicalModel(entdb.DictModels[2]); Got != entdb.DictModels[1] {
+		t.Errorf("test canonical model failed: got %v, wanted %v", Got, entdb.DictModels[1])
+	}
+
+	if Got := entdb.Suggest("blond", 10).Tags; len(Got) != 2 || Got[0].Count != 3 || Got[1].Count != 3 {
+		t.Errorf("test suggest tag aliases failed: got %v", Got)
+	}
+
+	// Indexes of videos added and removed later keep the aliases in sync
+	entdb.Remove(uint(3))
+	if len(entdb.Tags["blond"]) != 2 || len(entdb.Index().ByTag("blonde")) != 2 {
+		t.Errorf("test remove with aliases failed: got %v %v", len(entdb.Tags["blond"]), len(entdb.Index().ByTag("blonde")))
+	}
+
+	video := NewEntVideo(entdb)
+	video.Id = 5
+	video.Title = "blond kitty"
+	video.Slug = video.GetSlug()
+	video.AddTag(entdb.DictTags[2])
+	entdb.Add(video)
+	if len(entdb.Tags["blonde"]) != 3 || len(entdb.Index().ByTag("blond")) != 3 {
+		t.Errorf("test add with aliases failed: got %v %v", len(entdb.Tags["blonde"]), len(entdb.Index().ByTag("blond")))
+	}
+
+	// Without synonyms every tag has its own videos again
+	entdb.SetSynonyms(nil)
+	if len(entdb.Tags["blonde"]) != 1 || len(entdb.Tags["blond"]) != 2 {
+		t.Errorf("test drop synonyms failed: got %v %v", len(entdb.Tags["blonde"]), len(entdb.Tags["blond"]))
+	}
+}
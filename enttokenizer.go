@@ -0,0 +1,167 @@
+package goentdb
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+/*
+Splits text into the terms of the search and n-gram indexes.
+Titles are indexed and queries are looked up with the same tokenizer,
+so it has to be set before the first video is added.
+*/
+type EntTokenizer interface {
+	Tokens(text string) []string
+}
+
+/*
+Makes the url slug of a video title
+*/
+type EntSlugger interface {
+	Slug(text string) string
+}
+
+var Tokenizer EntTokenizer = UnicodeTokenizer{}
+
+var Slugger EntSlugger = UnicodeSlugger{Transliterate: true}
+
+/*
+Words are runs of letters, marks and digits of the NFKC normalized, case
+folded text, apostrophes are dropped from them. Han, Hiragana and Katakana
+are written without spaces, their runs are split into overlapping pairs.
+*/
+type UnicodeTokenizer struct{}
+
+func (UnicodeTokenizer) Tokens(text string) []string {
+	res := make([]string, 0)
+
+	var word strings.Builder
+	run := make([]rune, 0)
+
+	flush := func() {
+		if word.Len() > 0 {
+			res = append(res, word.String())
+			word.Reset()
+		}
+		if len(run) == 1 {
+			res = append(res, string(run))
+		}
+		for i := 0; i+1 < len(run); i++ {
+			res = append(res, string(run[i:i+2]))
+		}
+		run = run[:0]
+	}
+
+	for _, char := range foldText(text) {
+		switch {
+		case isIdeographic(char):
+			if word.Len() > 0 {
+				flush()
+			}
+			run = append(run, char)
+		case unicode.IsLetter(char) || unicode.IsNumber(char) || unicode.IsMark(char):
+			if len(run) > 0 {
+				flush()
+			}
+			word.WriteRune(char)
+		case char == '\'' || char == '’':
+		default:
+			flush()
+		}
+	}
+	flush()
+
+	return res
+}
+
+/*
+Keeps the letters and digits of the NFKC normalized, case folded text and
+turns spaces into dashes. With Transliterate accents are stripped and
+Cyrillic and Greek are spelled in ASCII, other scripts are kept as they are.
+*/
+type UnicodeSlugger struct {
+	Transliterate bool
+}
+
+func (s UnicodeSlugger) Slug(text string) string {
+	var buffer strings.Builder
+
+	for _, char := range foldText(text) {
+		switch {
+		case char == ' ':
+			buffer.WriteByte('-')
+		case s.Transliterate && char >= utf8.RuneSelf:
+			buffer.WriteString(transliterate(char))
+		case unicode.IsLetter(char) || unicode.IsNumber(char) || (unicode.IsMark(char) && !s.Transliterate):
+			buffer.WriteRune(char)
+		}
+	}
+
+	return buffer.String()
+}
+
+/*
+NFKC normalized and case folded text, the form every term is indexed in
+*/
+func foldText(text string) string {
+	return norm.NFKC.String(cases.Fold().String(norm.NFKC.String(text)))
+}
+
+func isIdeographic(char rune) bool {
+	return unicode.In(char, unicode.Han, unicode.Hiragana, unicode.Katakana) || char == 'ー'
+}
+
+/*
+ASCII spelling of a folded letter, the letter itself when there is none
+*/
+func transliterate(char rune) string {
+	if ascii, exists := transliterations[char]; exists {
+		return ascii
+	}
+	if !unicode.IsLetter(char) && !unicode.IsNumber(char) {
+		return ""
+	}
+
+	// Letters with accents are spelled as their base letter
+	base, _ := utf8.DecodeRuneInString(norm.NFD.String(string(char)))
+	if base < utf8.RuneSelf {
+		return string(base)
+	}
+	if ascii, exists := transliterations[base]; exists {
+		return ascii
+	}
+
+	return string(char)
+}
+
+var transliterations = map[rune]string{
+	// Latin letters without a decomposition
+	'æ': "ae", 'ð': "d", 'đ': "d", 'ħ': "h", 'ı': "i", 'ł': "l", 'ŋ': "ng", 'ø': "o", 'œ': "oe", 'þ': "th",
+
+	// Cyrillic
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e", 'ж': "zh", 'з': "z", 'и': "i",
+	'й': "y", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t",
+	'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch", 'ъ': "", 'ы': "y", 'ь': "",
+	'э': "e", 'ю': "yu", 'я': "ya", 'є': "ye", 'і': "i", 'ї': "yi", 'ґ': "g", 'ў': "u",
+
+	// Greek
+	'α': "a", 'β': "v", 'γ': "g", 'δ': "d", 'ε': "e", 'ζ': "z", 'η': "i", 'θ': "th", 'ι': "i", 'κ': "k",
+	'λ': "l", 'μ': "m", 'ν': "n", 'ξ': "x", 'ο': "o", 'π': "p", 'ρ': "r", 'σ': "s", 'ς': "s", 'τ': "t",
+	'υ': "y", 'φ': "f", 'χ': "ch", 'ψ': "ps", 'ω': "o",
+}
+
+/*
+Search and phrase terms are the tokenizer's terms, search drops the ones
+shorter than 3 letters. A Han or kana pair is a word of its own.
+*/
+func isSearchToken(token string) bool {
+	if utf8.RuneCountInString(token) >= 3 {
+		return true
+	}
+	char, _ := utf8.DecodeRuneInString(token)
+	return isIdeographic(char)
+}
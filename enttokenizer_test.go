@@ -0,0 +1,91 @@
+package goentdb
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnicodeTokenizer(t *testing.T) {
+	cases := []struct {
+		Text     string
+		Expected []string
+	}{
+		{Text: "Hot girl's day-off!", Expected: []string{"hot", "girls", "day", "off"}},
+		{Text: "Привет, МИР", Expected: []string{"привет", "мир"}},
+		{Text: "Schöne GRÜSSE aus München", Expected: []string{"schöne", "grüsse", "aus", "münchen"}},
+		{Text: "Straße", Expected: []string{"strasse"}},
+		{Text: "ＦＵＬＬ　ｗｉｄｔｈ １２３", Expected: []string{"full", "width", "123"}},
+		{Text: "東京タワーの夜景", Expected: []string{"東京", "京タ", "タワ", "ワー", "ーの", "の夜", "夜景"}},
+		{Text: "新作 AV女優", Expected: []string{"新作", "av", "女優"}},
+	}
+
+	for _, c := range cases {
+		Got := UnicodeTokenizer{}.Tokens(c.Text)
+		if strings.Join(Got, "|") != strings.Join(c.Expected, "|") {
+			t.Errorf("test tokens %q failed: got %v, wanted %v", c.Text, Got, c.Expected)
+		}
+	}
+}
+
+func TestUnicodeSlugger(t *testing.T) {
+	cases := []struct {
+		Text          string
+		Transliterate bool
+		Expected      string
+	}{
+		{Text: "Title with a stop-word #6!", Transliterate: true, Expected: "title-with-a-stopword-6"},
+		{Text: "Привет мир", Transliterate: true, Expected: "privet-mir"},
+		{Text: "Привет мир", Transliterate: false, Expected: "привет-мир"},
+		{Text: "Schöne Grüße aus München", Transliterate: true, Expected: "schone-grusse-aus-munchen"},
+		{Text: "Schöne Grüße", Transliterate: false, Expected: "schöne-grüsse"},
+		{Text: "Café Ørsted", Transliterate: true, Expected: "cafe-orsted"},
+		{Text: "東京タワーの夜景", Transliterate: true, Expected: "東京タワーの夜景"},
+		{Text: "ＡＢＣ　１２３", Transliterate: true, Expected: "abc-123"},
+	}
+
+	for _, c := range cases {
+		Got := UnicodeSlugger{Transliterate: c.Transliterate}.Slug(c.Text)
+		if Got != c.Expected {
+			t.Errorf("test slug %q failed: got %v, wanted %v", c.Text, Got, c.Expected)
+		}
+	}
+}
+
+func TestEntDBSearchUnicode(t *testing.T) {
+	entdb := GenerateSearchEntDB(t.TempDir(), []string{
+		"Привет мир из Москвы",
+		"Schöne Grüße aus München",
+		"東京タワーの夜景",
+		"Tokyo tower at night",
+	})
+
+	cases := []struct {
+		Query    string
+		Expected []uint
+	}{
+		{Query: "МИР москвы", Expected: []uint{1}},
+		{Query: "grüsse", Expected: []uint{2}},
+		{Query: "MÜNCHEN", Expected: []uint{2}},
+		{Query: "タワー", Expected: []uint{3}},
+		{Query: "\"東京タワー\"", Expected: []uint{3}},
+		{Query: "\"タワー 東京\"", Expected: []uint{}},
+	}
+
+	for _, c := range cases {
+		Got, _ := entdb.SearchRanked(c.Query, 10)
+		if len(Got) != len(c.Expected) {
+			t.Errorf("test search %q failed: got %v, wanted %v", c.Query, Got, c.Expected)
+			continue
+		}
+		for i, result := range Got {
+			if result.Video.Id != c.Expected[i] {
+				t.Errorf("test search %q failed: got %v, wanted %v", c.Query, result.Video.Id, c.Expected[i])
+			}
+		}
+	}
+
+	video, _ := entdb.GetVideoById(1)
+	if Got := video.GetSlug(); Got != "privet-mir-iz-moskvy" {
+		t.Errorf("test unicode slug failed: got %v, wanted %v", Got, "privet-mir-iz-moskvy")
+	}
+}
@@ -0,0 +1,220 @@
+package goentdb
+
+import (
+	"container/heap"
+	"sort"
+	"strings"
+)
+
+/*
+Persistent byte trie of suggestion terms with the number of videos using them.
+Shares the publishing scheme of entHAMT: the writer copies the path to a key
+on its first change after a publish, published nodes are never changed.
+Every node knows the highest count below it, so the most used terms under a
+prefix are found without walking the whole subtree.
+*/
+type entTrie struct {
+	root *trieNode
+	size int
+	edit uint64
+}
+
+type trieNode struct {
+	labels   []byte // Sorted, the first byte of the key suffix of each child
+	children []*trieNode
+	term     *EntSuggestion // Term ending here, nil for inner nodes
+	best     int            // Highest count of the terms in the subtree, -1 without terms
+	edit     uint64
+}
+
+type EntSuggestion struct {
+	Phrase  string
+	Keyword *EntKeyword // Tag or model, nil for title tokens
+	Count   int         // Videos using it
+}
+
+func newTrie() entTrie {
+	return entTrie{edit: 1}
+}
+
+func (t *entTrie) editable(node *trieNode) *trieNode {
+	if node == nil {
+		return &trieNode{best: -1, edit: t.edit}
+	}
+	if node.edit == t.edit {
+		return node
+	}
+
+	copied := *node
+	copied.labels = append(make([]byte, 0, len(node.labels)+1), node.labels...)
+	copied.children = append(make([]*trieNode, 0, len(node.children)+1), node.children...)
+	copied.edit = t.edit
+
+	return &copied
+}
+
+func (n *trieNode) child(label byte) (int, bool) {
+	pos := sort.Search(len(n.labels), func(i int) bool { return n.labels[i] >= label })
+	return pos, pos < len(n.labels) && n.labels[pos] == label
+}
+
+func (n *trieNode) updateBest() {
+	n.best = -1
+	if n.term != nil {
+		n.best = n.term.Count
+	}
+	for _, child := range n.children {
+		if child.best > n.best {
+			n.best = child.best
+		}
+	}
+}
+
+/*
+Set the term of key, nil removes it
+*/
+func (t *entTrie) set(key string, term *EntSuggestion) {
+	root, delta := t.update(t.root, key, term)
+	t.root = root
+	t.size += delta
+}
+
+func (t *entTrie) update(node *trieNode, key string, term *EntSuggestion) (*trieNode, int) {
+	if node == nil && term == nil {
+		return nil, 0
+	}
+	node = t.editable(node)
+	delta := 0
+
+	if key == "" {
+		if node.term == nil && term != nil {
+			delta = 1
+		} else if node.term != nil && term == nil {
+			delta = -1
+		}
+		node.term = term
+	} else {
+		pos, exists := node.child(key[0])
+
+		var child *trieNode
+		if exists {
+			child = node.children[pos]
+		}
+		child, delta = t.update(child, key[1:], term)
+
+		switch {
+		case child != nil && exists:
+			node.children[pos] = child
+		case child != nil:
+			node.labels = append(node.labels, 0)
+			copy(node.labels[pos+1:], node.labels[pos:])
+			node.labels[pos] = key[0]
+			node.children = append(node.children, nil)
+			copy(node.children[pos+1:], node.children[pos:])
+			node.children[pos] = child
+		case exists:
+			node.labels = append(node.labels[:pos], node.labels[pos+1:]...)
+			node.children = append(node.children[:pos], node.children[pos+1:]...)
+		}
+	}
+
+	// Nodes left without terms below them are pruned
+	if node.term == nil && len(node.children) == 0 {
+		return nil, delta
+	}
+
+	node.updateBest()
+	return node, delta
+}
+
+func (t *entTrie) find(key string) *trieNode {
+	node := t.root
+	for i := 0; node != nil && i < len(key); i++ {
+		pos, exists := node.child(key[i])
+		if !exists {
+			return nil
+		}
+		node = node.children[pos]
+	}
+	return node
+}
+
+func (t *entTrie) len() int {
+	return t.size
+}
+
+func (t *entTrie) freeze() {
+	t.edit++
+}
+
+/*
+Up to size terms under prefix, most used first, ties in key order
+*/
+func (t *entTrie) top(prefix string, size int) []EntSuggestion {
+	res := make([]EntSuggestion, 0)
+
+	node := t.find(prefix)
+	if node == nil || size <= 0 {
+		return res
+	}
+
+	queue := &trieQueue{{key: prefix, node: node, count: node.best}}
+	for queue.Len() > 0 && len(res) < size {
+		item := heap.Pop(queue).(trieItem)
+		if item.term != nil {
+			res = append(res, *item.term)
+			continue
+		}
+
+		if item.node.term != nil {
+			heap.Push(queue, trieItem{key: item.key, term: item.node.term, count: item.node.term.Count})
+		}
+		for pos, child := range item.node.children {
+			heap.Push(queue, trieItem{key: item.key + string(item.node.labels[pos]), node: child, count: child.best})
+		}
+	}
+
+	return res
+}
+
+/*
+Subtrees ordered by their best count and terms by their count. A subtree
+comes before the terms with its count and a larger key, so none of its
+terms can be passed over.
+*/
+type trieItem struct {
+	key   string
+	node  *trieNode
+	term  *EntSuggestion
+	count int
+}
+
+type trieQueue []trieItem
+
+func (q trieQueue) Len() int { return len(q) }
+
+func (q trieQueue) Less(i, j int) bool {
+	if q[i].count != q[j].count {
+		return q[i].count > q[j].count
+	}
+	if q[i].key != q[j].key {
+		return q[i].key < q[j].key
+	}
+	// The term of a node before its children
+	return q[i].term != nil && q[j].term == nil
+}
+
+func (q trieQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *trieQueue) Push(x interface{}) { *q = append(*q, x.(trieItem)) }
+
+func (q *trieQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
+
+func suggestKey(phrase string) string {
+	return foldText(strings.TrimSpace(phrase))
+}
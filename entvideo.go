@@ -1,7 +1,6 @@
 package goentdb
 
 import (
-	"bytes"
 	"fmt"
 	"html"
 	"math/rand"
@@ -104,21 +103,11 @@ func (ev *EntVideo) GetThumb() string {
 	return fmt.Sprintf("%s/%s", ev.Owner.ThumbBaseUrl, ev.GetPosterThumbRelatedPath())
 }
 
+/*
+Url slug of the title made by Slugger
+*/
 func (ev *EntVideo) GetSlug() string {
-	lc := strings.ToLower(ev.Title)
-	var buffer bytes.Buffer
-
-	for _, char := range lc {
-		if (char-'a' >= 0 && char-'a' < 26) || (char-'0' >= 0 && char-'0' < 10) || (char-' ' == 0) {
-			if char == ' ' {
-				buffer.WriteRune('-')
-			} else {
-				buffer.WriteRune(char)
-			}
-		}
-	}
-
-	return buffer.String()
+	return Slugger.Slug(ev.Title)
 }
 
 func (ev *EntVideo) GetMD5() string {
@@ -163,7 +152,7 @@ func (ev *EntVideo) GetKeywordBySlug(Slug string) (*EntKeyword, error) {
 			return kw, nil
 		}
 	}
-	return nil, fmt.Errorf("EntVideo %d does not have %s", ev.Id, Slug)
+	return nil, notFound(EntKindKeyword, Slug)
 }
 
 func (ev *EntVideo) ToLoad() EntVideoForLoad {
@@ -199,31 +188,47 @@ func NewEntVideo(edb *EntDB) *EntVideo {
 	}
 }
 
+/*
+Terms of the title as Tokenizer splits it, keyed in the n-gram indexes.
+Stop words are the ones of the analyzer of the video.
+*/
 func (v *EntVideo) GetTitleTokens(excludeStopWords bool) []string {
-	lcTitle := strings.ToLower(v.GetTitle())
-	var buffer bytes.Buffer
+	stopWords := v.analyzer().StopWords
 
 	res := make([]string, 0)
-
-	for _, char := range lcTitle {
-		if (char-'a' >= 0 && char-'a' < 26) || (char-'0' >= 0 && char-'0' < 10) || (char-' ' == 0) {
-			if char == ' ' {
-				res = append(res, buffer.String())
-				if excludeStopWords {
-					if _, exists := StopWordsMap[res[len(res)-1]]; exists {
-						res = res[:len(res)-1]
-					}
-				}
-				buffer.Reset()
-			} else {
-				buffer.WriteRune(char)
-			}
+	for _, token := range Tokenizer.Tokens(v.GetTitle()) {
+		if excludeStopWords && stopWords[token] {
+			continue
 		}
+		res = append(res, token)
 	}
-	res = append(res, buffer.String())
-	if excludeStopWords {
-		if _, exists := StopWordsMap[res[len(res)-1]]; exists {
-			res = res[:len(res)-1]
+	return res
+}
+
+/*
+Terms of the title used as keys of the EntDB.Search index, see EntAnalyzer
+*/
+func (v *EntVideo) GetSearchTokens() []string {
+	return v.analyzer().Terms(v.GetTitle())
+}
+
+/*
+Terms of the description, ranked as the descr field by text search
+*/
+func (v *EntVideo) GetDescrTokens() []string {
+	return v.analyzer().Terms(v.Descr)
+}
+
+/*
+Terms of tag, model and keyword phrases, ranked as the keywords field by text search
+*/
+func (v *EntVideo) GetKeywordTokens() []string {
+	analyzer := v.analyzer()
+
+	res := make([]string, 0)
+	for _, keywords := range [][]*EntKeyword{v.Tags, v.Models, v.Keywords} {
+		for _, keyword := range keywords {
+			res = append(res, analyzer.Terms(keyword.Phrase)...)
 		}
 	}
 	return res
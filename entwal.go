@@ -0,0 +1,280 @@
+package goentdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path"
+)
+
+type EntWALOp uint8
+
+const (
+	EntWALAddTag EntWALOp = iota + 1
+	EntWALAddModel
+	EntWALAddVideo
+	EntWALUpdateVideo
+	EntWALRemoveVideo
+	EntWALRenameTag
+	EntWALRenameModel
+	EntWALMergeTags
+	EntWALMergeModels
+)
+
+/*
+Single mutation stored in the write-ahead log
+Only the fields relevant for the Op are filled
+*/
+type EntWALRecord struct {
+	Op      EntWALOp
+	Keyword *EntKeyword
+	Video   *EntVideoForLoad
+	Id      uint
+	Ids     []int // Keywords merged into Keyword
+
+	// Snapshot generation the state was loaded from when the record was
+	// logged, 0 for records logged before generations were stamped
+	Generation uint64
+}
+
+/*
+Append-only log of EntDB mutations
+Every record is framed as: length (uint32) | crc32 (uint32) | gob payload
+so a torn write at the tail can be detected and dropped.
+*/
+type EntWAL struct {
+	Path  string
+	Sync  bool
+	f     *os.File
+	size  int64
+	write func(frames []byte) (int, error) // Writes to f, replaced by tests to inject failures
+}
+
+const walFrameHeaderSize = 8
+
+var (
+	errWALTornRecord = errors.New("torn wal record")
+	errWALChecksum   = errors.New("wal record checksum mismatch")
+)
+
+/*
+Open WAL for appending, the file is created if missing.
+A torn record left at the tail by a crash is cut off.
+*/
+func OpenEntWAL(path string) (*EntWAL, error) {
+	valid, err := ReadEntWAL(path, func(*EntWALRecord) error { return nil })
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := f.Truncate(valid); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	if _, err := f.Seek(valid, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return &EntWAL{Path: path, f: f, size: valid, write: f.Write}, nil
+}
+
+/*
+Append recs with a single write, either all of them are logged or none.
+A failed or short write is cut off again, so records appended later do not
+end up behind a partial frame which replay stops at.
+*/
+func (w *EntWAL) Append(recs ...*EntWALRecord) error {
+	var frames bytes.Buffer
+	for _, rec := range recs {
+		var payload bytes.Buffer
+		if err := gob.NewEncoder(&payload).Encode(rec); err != nil {
+			return err
+		}
+
+		header := make([]byte, walFrameHeaderSize)
+		binary.LittleEndian.PutUint32(header[0:4], uint32(payload.Len()))
+		binary.LittleEndian.PutUint32(header[4:8], crc32.ChecksumIEEE(payload.Bytes()))
+		frames.Write(header)
+		frames.Write(payload.Bytes())
+	}
+
+	n, err := w.write(frames.Bytes())
+	if err == nil && w.Sync {
+		err = w.f.Sync()
+	}
+	if err != nil {
+		return w.cutOff(err)
+	}
+	w.size += int64(n)
+
+	return nil
+}
+
+/*
+Truncate back to the last complete record after a failed append
+*/
+func (w *EntWAL) cutOff(cause error) error {
+	if err := w.f.Truncate(w.size); err != nil {
+		return fmt.Errorf("%w, cut off failed: %v", cause, err)
+	}
+	if _, err := w.f.Seek(w.size, io.SeekStart); err != nil {
+		return fmt.Errorf("%w, cut off failed: %v", cause, err)
+	}
+	return cause
+}
+
+func (w *EntWAL) Size() int64 {
+	return w.size
+}
+
+/*
+Drop every record, used once the records are part of a snapshot
+*/
+func (w *EntWAL) Truncate() error {
+	if err := w.f.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := w.f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	w.size = 0
+	return w.f.Sync()
+}
+
+/*
+Move every record to the end of segment and continue with an empty log.
+Records already in segment are kept, a torn tail there is cut off first.
+*/
+func (w *EntWAL) Rotate(segment string) error {
+	valid, err := ReadEntWAL(segment, func(*EntWALRecord) error { return nil })
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	out, err := os.OpenFile(segment, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	fail := func(err error) error {
+		out.Truncate(valid)
+		out.Close()
+		return err
+	}
+
+	if err := out.Truncate(valid); err != nil {
+		return fail(err)
+	}
+	if _, err := out.Seek(valid, io.SeekStart); err != nil {
+		return fail(err)
+	}
+	if _, err := w.f.Seek(0, io.SeekStart); err != nil {
+		return fail(err)
+	}
+	if _, err := io.CopyN(out, w.f, w.size); err != nil {
+		w.f.Seek(w.size, io.SeekStart)
+		return fail(err)
+	}
+	if err := out.Sync(); err != nil {
+		return fail(err)
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	if err := SyncDir(path.Dir(segment)); err != nil {
+		return err
+	}
+
+	return w.Truncate()
+}
+
+func (w *EntWAL) Close() error {
+	return w.f.Close()
+}
+
+/*
+Read every record of the WAL at path and pass it to fn
+Returns the offset right after the last complete record.
+A torn record at the tail is not an error, the read just stops there.
+*/
+func ReadEntWAL(path string, fn func(*EntWALRecord) error) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	var offset int64
+	header := make([]byte, walFrameHeaderSize)
+
+	for {
+		rec, n, err := readWALFrame(f, header, stat.Size()-offset)
+		if err == io.EOF || err == errWALTornRecord {
+			return offset, nil
+		}
+		// A bad checksum on the last record is a torn write, anywhere else it is corruption
+		if err == errWALChecksum && offset+n == stat.Size() {
+			return offset, nil
+		}
+		if err == errWALChecksum {
+			return offset, corrupt(path, fmt.Errorf("wal record at offset %d: %w", offset, err))
+		}
+		if err != nil {
+			return offset, fmt.Errorf("wal %s at offset %d: %w", path, offset, err)
+		}
+
+		if err := fn(rec); err != nil {
+			return offset, fmt.Errorf("wal %s at offset %d: %w", path, offset, err)
+		}
+		offset += n
+	}
+}
+
+func readWALFrame(r io.Reader, header []byte, remaining int64) (*EntWALRecord, int64, error) {
+	if _, err := io.ReadFull(r, header); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, 0, errWALTornRecord
+		}
+		return nil, 0, err
+	}
+
+	size := binary.LittleEndian.Uint32(header[0:4])
+	checksum := binary.LittleEndian.Uint32(header[4:8])
+
+	if int64(size) > remaining-walFrameHeaderSize {
+		return nil, 0, errWALTornRecord
+	}
+
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, 0, errWALTornRecord
+	}
+
+	if crc32.ChecksumIEEE(payload) != checksum {
+		return nil, int64(walFrameHeaderSize + len(payload)), errWALChecksum
+	}
+
+	rec := &EntWALRecord{}
+	if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(rec); err != nil {
+		return nil, 0, err
+	}
+
+	return rec, int64(walFrameHeaderSize + len(payload)), nil
+}
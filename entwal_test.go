@@ -0,0 +1,250 @@
+package goentdb
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+func GenerateWALEntDB(t *testing.T, path string) *EntDB {
+	entdb := NewEntDB(path)
+	if err := entdb.OpenWAL(); err != nil {
+		t.Fatalf("test open wal failed: %v", err)
+	}
+
+	for i := 1; i <= 3; i++ {
+		if err := entdb.AddTag(NewTag(i, fmt.Sprintf("tag %d", i))); err != nil {
+			t.Errorf("test wal add tag failed: %v", err)
+		}
+	}
+	entdb.AddModel(NewModel(1, "model 1"))
+
+	for i := 1; i <= 5; i++ {
+		video := NewEntVideo(entdb)
+		video.Id = uint(i)
+		video.Title = fmt.Sprintf("title number %d", i)
+		video.Slug = fmt.Sprintf("title-number-%d", i)
+		video.AddTag(entdb.DictTags[1+i%3])
+		video.AddModel(entdb.DictModels[1])
+		if err := entdb.Add(video); err != nil {
+			t.Errorf("test wal add video failed: %v", err)
+		}
+	}
+
+	return entdb
+}
+
+func TestEntWALReplay(t *testing.T) {
+	path := t.TempDir()
+	entdb := GenerateWALEntDB(t, path)
+
+	entdb.Remove(uint(2))
+
+	video := NewEntVideo(entdb)
+	video.Id = uint(3)
+	video.Title = "updated title"
+	video.Slug = "updated-title"
+	video.AddTag(entdb.DictTags[3])
+	entdb.Update(video)
+
+	entdb.CloseWAL()
+
+	entdb_new := NewEntDB(path)
+	entdb_new.Load()
+
+	Expected := 4
+	Got := len(entdb_new.Items)
+	if Got != Expected {
+		t.Errorf("test wal replay items count failed: got %v, wanted %v", Got, Expected)
+	}
+
+	Expected = 3
+	Got = len(entdb_new.DictTags)
+	if Got != Expected {
+		t.Errorf("test wal replay tags count failed: got %v, wanted %v", Got, Expected)
+	}
+
+	if _, err := entdb_new.GetVideoById(uint(2)); err == nil {
+		t.Errorf("test wal replay removed video should be gone")
+	}
+
+	Got3, err := entdb_new.GetVideoById(uint(3))
+	if err != nil || Got3.Title != "updated title" {
+		t.Errorf("test wal replay updated video failed: got %v, wanted %v", Got3, video)
+	}
+
+	Expected = 3
+	Got = len(entdb_new.Models["model-1"])
+	if Got != Expected {
+		t.Errorf("test wal replay model index failed: got %v, wanted %v", Got, Expected)
+	}
+}
+
+func TestEntWALTornTail(t *testing.T) {
+	path := t.TempDir()
+	entdb := GenerateWALEntDB(t, path)
+	entdb.CloseWAL()
+
+	stat, _ := os.Stat(entdb.GetWALPath())
+	valid := stat.Size()
+
+	f, _ := os.OpenFile(entdb.GetWALPath(), os.O_APPEND|os.O_WRONLY, 0644)
+	f.Write([]byte{200, 0, 0, 0, 1, 2, 3})
+	f.Close()
+
+	records := 0
+	offset, err := ReadEntWAL(entdb.GetWALPath(), func(*EntWALRecord) error {
+		records++
+		return nil
+	})
+	if err != nil {
+		t.Errorf("test wal torn tail read failed: %v", err)
+	}
+
+	Expected := 9
+	if records != Expected {
+		t.Errorf("test wal torn tail records failed: got %v, wanted %v", records, Expected)
+	}
+	if offset != valid {
+		t.Errorf("test wal torn tail offset failed: got %v, wanted %v", offset, valid)
+	}
+
+	wal, err := OpenEntWAL(entdb.GetWALPath())
+	if err != nil {
+		t.Fatalf("test wal torn tail open failed: %v", err)
+	}
+	defer wal.Close()
+
+	if wal.Size() != valid {
+		t.Errorf("test wal torn tail truncate failed: got %v, wanted %v", wal.Size(), valid)
+	}
+}
+
+func TestEntWALCompaction(t *testing.T) {
+	path := t.TempDir()
+
+	entdb := NewEntDB(path)
+	entdb.WALThreshold = 1024
+	entdb.OpenWAL()
+
+	for i := 1; i <= 50; i++ {
+		entdb.AddTag(NewTag(i, fmt.Sprintf("tag %d", i)))
+	}
+
+	stat, _ := os.Stat(entdb.GetWALPath())
+	if stat.Size() >= entdb.WALThreshold {
+		t.Errorf("test wal compaction size failed: got %v, wanted < %v", stat.Size(), entdb.WALThreshold)
+	}
+
+	if _, err := entdb.ReadManifest(); err != nil {
+		t.Errorf("test wal compaction snapshot missing: %v", err)
+	}
+
+	entdb.CloseWAL()
+
+	entdb_new := NewEntDB(path)
+	entdb_new.Load()
+
+	Expected := 50
+	Got := len(entdb_new.DictTags)
+	if Got != Expected {
+		t.Errorf("test wal compaction tags count failed: got %v, wanted %v", Got, Expected)
+	}
+}
+
+func TestEntWALAppendFailure(t *testing.T) {
+	path := t.TempDir()
+	entdb := GenerateWALEntDB(t, path)
+	wal := entdb.wal
+
+	valid := wal.Size()
+	write := wal.write
+	wal.write = func(frames []byte) (int, error) {
+		n, _ := write(frames[:len(frames)/2])
+		return n, fmt.Errorf("disk full")
+	}
+
+	if err := entdb.AddTag(NewTag(4, "tag 4")); err == nil {
+		t.Errorf("test wal append failure should fail")
+	}
+	if stat, _ := os.Stat(entdb.GetWALPath()); stat.Size() != valid || wal.Size() != valid {
+		t.Errorf("test wal append failure cut off failed: got %v/%v, wanted %v", stat.Size(), wal.Size(), valid)
+	}
+
+	// Records acknowledged after the failure are replayed
+	wal.write = write
+	if err := entdb.AddTag(NewTag(5, "tag 5")); err != nil {
+		t.Errorf("test wal append after failure failed: %v", err)
+	}
+	entdb.CloseWAL()
+
+	entdb_new := NewEntDB(path)
+	if err := entdb_new.Load(); err != nil {
+		t.Fatalf("test wal append failure load failed: %v", err)
+	}
+	if _, exists := entdb_new.DictTags[5]; !exists {
+		t.Errorf("test wal append failure replay lost a record")
+	}
+	if _, exists := entdb_new.DictTags[4]; exists {
+		t.Errorf("test wal append failure replay has the failed record")
+	}
+}
+
+func TestEntWALAddBatchFailure(t *testing.T) {
+	path := t.TempDir()
+	entdb := GenerateWALEntDB(t, path)
+	wal := entdb.wal
+
+	valid := wal.Size()
+	wal.write = func(frames []byte) (int, error) {
+		return 0, fmt.Errorf("disk full")
+	}
+
+	videos := make([]*EntVideo, 0)
+	for i := 6; i <= 8; i++ {
+		video := NewEntVideo(entdb)
+		video.Id = uint(i)
+		video.Title = fmt.Sprintf("title number %d", i)
+		video.Slug = fmt.Sprintf("title-number-%d", i)
+		videos = append(videos, video)
+	}
+
+	if err := entdb.AddBatch(videos); err == nil {
+		t.Errorf("test wal add batch failure should fail")
+	}
+	if Got := entdb.Index().Len(); Got != 5 {
+		t.Errorf("test wal add batch failure items failed: got %v, wanted %v", Got, 5)
+	}
+	if wal.Size() != valid {
+		t.Errorf("test wal add batch failure wal size failed: got %v, wanted %v", wal.Size(), valid)
+	}
+}
+
+func TestEntWALCompactionFailure(t *testing.T) {
+	path := t.TempDir()
+
+	entdb := NewEntDB(path)
+	entdb.WALThreshold = 1
+	entdb.Codec = EntCodecId(99)
+	entdb.OpenWAL()
+	defer entdb.CloseWAL()
+
+	// The tag is committed even though the snapshot can not be written
+	if err := entdb.AddTag(NewTag(1, "tag 1")); err != nil {
+		t.Errorf("test wal compaction failure should not fail the mutation: %v", err)
+	}
+	if _, err := entdb.GetTagById(1); err != nil {
+		t.Errorf("test wal compaction failure lost the tag: %v", err)
+	}
+	if entdb.CompactionErr() == nil {
+		t.Errorf("test wal compaction failure should be kept")
+	}
+
+	entdb.Codec = CodecNone
+	if err := entdb.Dump(); err != nil {
+		t.Fatalf("test wal compaction failure dump failed: %v", err)
+	}
+	if err := entdb.CompactionErr(); err != nil {
+		t.Errorf("test wal compaction failure should clear after dump: got %v", err)
+	}
+}
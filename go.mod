@@ -1,3 +1,5 @@
 module github.com/bp72/goentdb
 
 go 1.18
+
+require golang.org/x/text v0.14.0
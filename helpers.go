@@ -2,9 +2,13 @@ package goentdb
 
 import (
 	"crypto/md5"
+	"crypto/sha256"
 	"encoding/gob"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"os"
+	"path"
 	"sync"
 )
 
@@ -13,38 +17,174 @@ func MD5(i string) string {
 	return fmt.Sprintf("%x", md5.Sum(data))
 }
 
-func LoadMapFromFilepath(filepath string, dict *map[int]*EntKeyword, lock sync.RWMutex) error {
-	f, err := os.Open(filepath)
+/*
+File is decoded without the lock, lock is only held while the records are merged into dict
+*/
+func LoadMapFromFilepath(filepath string, dict *map[int]*EntKeyword, lock *sync.RWMutex) error {
+	loaded := make(map[int]*EntKeyword)
+
+	hdr, err := DecodeEntFile(filepath, EntFileKeywords, &loaded)
 	if err != nil {
 		return err
 	}
-	defer f.Close()
+
+	if hdr.Count > 0 && hdr.Count != uint64(len(loaded)) {
+		return corrupt(filepath, fmt.Errorf("header has %d records, decoded %d", hdr.Count, len(loaded)))
+	}
 
 	lock.Lock()
 	defer lock.Unlock()
 
-	decoder := gob.NewDecoder(f)
-	decoder.Decode(dict)
+	for id, keyword := range loaded {
+		(*dict)[id] = keyword
+	}
 
 	return nil
 }
 
-func DumpMapToFilepath(filepath string, dict map[int]*EntKeyword, lock sync.RWMutex) error {
-	f, err := os.Create(filepath)
+/*
+Dict is copied under the read lock and written without it
+*/
+func DumpMapToFilepath(filepath string, dict map[int]*EntKeyword, lock *sync.RWMutex) error {
+	lock.RLock()
+	dict = CopyKeywords(dict)
+	lock.RUnlock()
+
+	_, err := EncodeEntFile(filepath, EntFileKeywords, CodecNone, len(dict), dict)
+	return err
+}
+
+func CopyKeywords(dict map[int]*EntKeyword) map[int]*EntKeyword {
+	res := make(map[int]*EntKeyword, len(dict))
+	for id, keyword := range dict {
+		res[id] = keyword
+	}
+	return res
+}
+
+func EncodeToFilepath(filepath string, v interface{}) error {
+	_, err := WriteFileAtomic(filepath, func(w io.Writer) error {
+		return gob.NewEncoder(w).Encode(v)
+	})
+	return err
+}
+
+type FileChecksum struct {
+	Size   int64
+	SHA256 string
+}
+
+/*
+Write file via temp file in the same dir: write, fsync, rename, fsync dir.
+Readers see either the old or the new file, never a truncated one.
+*/
+func WriteFileAtomic(filepath string, write func(w io.Writer) error) (FileChecksum, error) {
+	dir, name := path.Split(filepath)
+	if dir == "" {
+		dir = "."
+	}
+
+	f, err := os.CreateTemp(dir, name+".tmp-*")
 	if err != nil {
-		return err
+		return FileChecksum{}, err
+	}
+	tmp := f.Name()
+
+	fail := func(err error) (FileChecksum, error) {
+		f.Close()
+		os.Remove(tmp)
+		return FileChecksum{}, err
 	}
 
-	encoder := gob.NewEncoder(f)
+	hash := sha256.New()
+	counter := &countingWriter{w: io.MultiWriter(f, hash)}
 
-	lock.RLock()
-	defer lock.RUnlock()
+	if err := write(counter); err != nil {
+		return fail(err)
+	}
+	if err := f.Sync(); err != nil {
+		return fail(err)
+	}
+	if err := f.Close(); err != nil {
+		return fail(err)
+	}
+	if err := os.Rename(tmp, filepath); err != nil {
+		os.Remove(tmp)
+		return FileChecksum{}, err
+	}
+	if err := SyncDir(dir); err != nil {
+		return FileChecksum{}, err
+	}
 
-	if err := encoder.Encode(dict); err != nil {
+	return FileChecksum{Size: counter.n, SHA256: hex.EncodeToString(hash.Sum(nil))}, nil
+}
+
+func SyncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
 		return err
 	}
-	f.Close()
-	return nil
+	defer d.Close()
+
+	return d.Sync()
+}
+
+func ChecksumFile(filepath string) (FileChecksum, error) {
+	f, err := os.Open(filepath)
+	if err != nil {
+		return FileChecksum{}, err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	n, err := io.Copy(hash, f)
+	if err != nil {
+		return FileChecksum{}, err
+	}
+
+	return FileChecksum{Size: n, SHA256: hex.EncodeToString(hash.Sum(nil))}, nil
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+/*
+Return a copy of videos without video
+A new slice is allocated so slices handed out earlier stay untouched
+*/
+func RemoveVideo(videos []*EntVideo, video *EntVideo) []*EntVideo {
+	res := make([]*EntVideo, 0, len(videos))
+	for _, v := range videos {
+		if v != video {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+/*
+Remove video from index[key], the key is dropped once no videos left
+*/
+func RemoveVideoFromIndex(index map[string][]*EntVideo, key string, video *EntVideo) {
+	videos, exists := index[key]
+	if !exists {
+		return
+	}
+
+	videos = RemoveVideo(videos, video)
+	if len(videos) == 0 {
+		delete(index, key)
+		return
+	}
+	index[key] = videos
 }
 
 func Min(a, b int) int {